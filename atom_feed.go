@@ -0,0 +1,108 @@
+package main
+
+import "strings"
+
+// Atom 1.0 documents have a <feed> root with <entry> children instead of RSS's
+// <rss><channel><item> shape, so they get their own set of structs:
+type AtomFeed struct {
+	Title    AtomText    `xml:"title"`
+	Subtitle AtomText    `xml:"subtitle"`
+	Links    []AtomLink  `xml:"link"`
+	Entries  []AtomEntry `xml:"entry"`
+}
+
+type AtomEntry struct {
+	ID        string     `xml:"id"`
+	Title     AtomText   `xml:"title"`
+	Links     []AtomLink `xml:"link"`
+	Updated   string     `xml:"updated"`
+	Published string     `xml:"published"`
+	Summary   AtomText   `xml:"summary"`
+	Content   AtomText   `xml:"content"`
+}
+
+// Atom links are empty elements that carry everything in attributes, e.g.
+// <link rel="alternate" type="text/html" href="https://example.com/post"/>
+type AtomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+// AtomText is an Atom text construct (title, subtitle, summary, content). Its type attribute
+// says whether the body is plain text, escaped HTML, or inline XHTML markup:
+type AtomText struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+// String returns the usable body of the text construct. For type="xhtml" the markup lives
+// in child elements, so we need the raw inner XML rather than just the character data:
+func (t AtomText) String() string {
+	if t.Type == "xhtml" {
+		return strings.TrimSpace(t.Inner)
+	}
+	return strings.TrimSpace(t.Text)
+}
+
+// toFeedData converts an Atom 1.0 document into the common FeedData model:
+func (atomFeed *AtomFeed) toFeedData() *FeedData {
+	feedData := &FeedData{
+		Title:       atomFeed.Title.String(),
+		Link:        alternateLink(atomFeed.Links),
+		Description: atomFeed.Subtitle.String(),
+	}
+	for _, entry := range atomFeed.Entries {
+		// Prefer the summary as the description, but plenty of feeds only ship <content>:
+		description := entry.Summary.String()
+		if description == "" {
+			description = entry.Content.String()
+		}
+		// Atom attaches media files as <link rel="enclosure">:
+		var enclosures []FeedEnclosure
+		for _, link := range entry.Links {
+			if link.Rel != "enclosure" || link.Href == "" {
+				continue
+			}
+			enclosures = append(enclosures, FeedEnclosure{
+				URL:      link.Href,
+				MimeType: link.Type,
+				Length:   parseLength(link.Length),
+			})
+		}
+		feedData.Items = append(feedData.Items, FeedItem{
+			GUID:        entry.ID,
+			Title:       entry.Title.String(),
+			Link:        alternateLink(entry.Links),
+			Description: description,
+			Content:     entry.Content.String(),
+			// <published> is optional in Atom, while <updated> is required:
+			PubDates:   []string{entry.Published, entry.Updated},
+			Enclosures: enclosures,
+		})
+	}
+	return feedData
+}
+
+// alternateLink picks the link that points at the human-readable page. A <link> without a
+// rel attribute is rel="alternate" by definition; an HTML alternate wins over other types:
+func alternateLink(links []AtomLink) string {
+	var alternate string
+	for _, link := range links {
+		if link.Rel != "" && link.Rel != "alternate" {
+			continue
+		}
+		if link.Type == "" || link.Type == "text/html" {
+			return link.Href
+		}
+		if alternate == "" {
+			alternate = link.Href
+		}
+	}
+	if alternate == "" && len(links) > 0 {
+		return links[0].Href
+	}
+	return alternate
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"database/sql"
+	"time"
+
+	"gator/internal/database"
+	"github.com/google/uuid"
+)
+
+// A backup is a JSON Lines file: a backupHeader on the first line, followed by one
+// backupRecord per line. Records are written parents first (users, then feeds with their
+// previous URLs and pruned posts, follows, posts, their enclosures and revisions, reads and
+// stars), so restore can map each one's references as it goes.
+// Bump backupVersion whenever the format changes in a way older versions of restore wouldn't
+// understand; restore refuses backups newer than it knows about:
+	// version 1: users, feeds, feed follows and posts
+	// version 2: adds post reads
+	// version 3: adds post stars
+	// version 4: adds enclosures, post revisions and previous feed URLs
+	// version 5: adds pruned posts
+const (
+	backupFormat  = "gator-backup"
+	backupVersion = 5
+)
+
+type backupHeader struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// backupRecord holds one row. Type says which of the other fields is set:
+type backupRecord struct {
+	Type            string                 `json:"type"`
+	User            *backupUser            `json:"user,omitempty"`
+	Feed            *backupFeed            `json:"feed,omitempty"`
+	FeedPreviousURL *backupFeedPreviousURL `json:"feed_previous_url,omitempty"`
+	PrunedPost      *backupPrunedPost      `json:"pruned_post,omitempty"`
+	FeedFollow      *backupFeedFollow      `json:"feed_follow,omitempty"`
+	Post            *backupPost            `json:"post,omitempty"`
+	Enclosure       *backupEnclosure       `json:"enclosure,omitempty"`
+	PostRevision    *backupPostRevision    `json:"post_revision,omitempty"`
+	PostRead        *backupPostRead        `json:"post_read,omitempty"`
+	PostStar        *backupPostStar        `json:"post_star,omitempty"`
+}
+
+// The backed-up tables. Only what people created is kept, not agg's bookkeeping (cache
+// validators, schedules, errors), which a restored feed simply builds up again. Empty strings
+// stand in for NULL text columns:
+type backupUser struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Name      string    `json:"name"`
+}
+
+type backupFeed struct {
+	ID                   uuid.UUID `json:"id"`
+	CreatedAt            time.Time `json:"created_at"`
+	UpdatedAt            time.Time `json:"updated_at"`
+	Name                 string    `json:"name"`
+	URL                  string    `json:"url"`
+	UserID               uuid.UUID `json:"user_id"`
+	Description          string    `json:"description,omitempty"`
+	SiteURL              string    `json:"site_url,omitempty"`
+	FetchIntervalSeconds *int32    `json:"fetch_interval_seconds,omitempty"`
+}
+
+type backupFeedPreviousURL struct {
+	URL       string    `json:"url"`
+	CreatedAt time.Time `json:"created_at"`
+	FeedID    uuid.UUID `json:"feed_id"`
+}
+
+type backupPrunedPost struct {
+	FeedID   uuid.UUID `json:"feed_id"`
+	GUID     string    `json:"guid"`
+	PrunedAt time.Time `json:"pruned_at"`
+}
+
+type backupFeedFollow struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserID    uuid.UUID `json:"user_id"`
+	FeedID    uuid.UUID `json:"feed_id"`
+	Folder    string    `json:"folder,omitempty"`
+}
+
+type backupPost struct {
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	FeedID      uuid.UUID  `json:"feed_id"`
+	GUID        string     `json:"guid"`
+	Title       string     `json:"title"`
+	URL         string     `json:"url"`
+	Description string     `json:"description,omitempty"`
+	Content     string     `json:"content,omitempty"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+	ContentHash string     `json:"content_hash,omitempty"`
+	// PublishedAtInferred is set when published_at is only the time the post was first seen:
+	PublishedAtInferred bool `json:"published_at_inferred,omitempty"`
+}
+
+type backupEnclosure struct {
+	ID              uuid.UUID `json:"id"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+	PostID          uuid.UUID `json:"post_id"`
+	URL             string    `json:"url"`
+	MimeType        string    `json:"mime_type,omitempty"`
+	Length          *int64    `json:"length,omitempty"`
+	DurationSeconds *int32    `json:"duration_seconds,omitempty"`
+}
+
+type backupPostRevision struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	PostID      uuid.UUID `json:"post_id"`
+	Title       string    `json:"title"`
+	URL         string    `json:"url"`
+	Description string    `json:"description,omitempty"`
+	Content     string    `json:"content,omitempty"`
+	ContentHash string    `json:"content_hash,omitempty"`
+}
+
+type backupPostRead struct {
+	UserID uuid.UUID `json:"user_id"`
+	PostID uuid.UUID `json:"post_id"`
+	ReadAt time.Time `json:"read_at"`
+}
+
+type backupPostStar struct {
+	UserID    uuid.UUID `json:"user_id"`
+	PostID    uuid.UUID `json:"post_id"`
+	StarredAt time.Time `json:"starred_at"`
+}
+
+func newBackupUser(user database.User) *backupUser {
+	return &backupUser{
+		ID:        user.ID,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+		Name:      user.Name,
+	}
+}
+
+func newBackupFeed(feed database.Feed) *backupFeed {
+	backup := &backupFeed{
+		ID:          feed.ID,
+		CreatedAt:   feed.CreatedAt,
+		UpdatedAt:   feed.UpdatedAt,
+		Name:        feed.Name,
+		URL:         feed.Url,
+		UserID:      feed.UserID,
+		Description: feed.Description.String,
+		SiteURL:     feed.SiteUrl.String,
+	}
+	if feed.FetchIntervalSeconds.Valid {
+		backup.FetchIntervalSeconds = &feed.FetchIntervalSeconds.Int32
+	}
+	return backup
+}
+
+func newBackupFeedPreviousURL(previous database.FeedPreviousUrl) *backupFeedPreviousURL {
+	return &backupFeedPreviousURL{
+		URL:       previous.Url,
+		CreatedAt: previous.CreatedAt,
+		FeedID:    previous.FeedID,
+	}
+}
+
+func newBackupPrunedPost(pruned database.PrunedPost) *backupPrunedPost {
+	return &backupPrunedPost{
+		FeedID:   pruned.FeedID,
+		GUID:     pruned.Guid,
+		PrunedAt: pruned.PrunedAt,
+	}
+}
+
+func newBackupFeedFollow(follow database.FeedFollow) *backupFeedFollow {
+	return &backupFeedFollow{
+		ID:        follow.ID,
+		CreatedAt: follow.CreatedAt,
+		UpdatedAt: follow.UpdatedAt,
+		UserID:    follow.UserID,
+		FeedID:    follow.FeedID,
+		Folder:    follow.Folder.String,
+	}
+}
+
+func newBackupPost(post database.Post) *backupPost {
+	backup := &backupPost{
+		ID:          post.ID,
+		CreatedAt:   post.CreatedAt,
+		UpdatedAt:   post.UpdatedAt,
+		FeedID:      post.FeedID,
+		GUID:        post.Guid,
+		Title:       post.Title,
+		URL:         post.Url,
+		Description: post.Description.String,
+		Content:     post.Content.String,
+		ContentHash: post.ContentHash.String,
+
+		PublishedAtInferred: post.PublishedAtInferred,
+	}
+	if post.PublishedAt.Valid {
+		backup.PublishedAt = &post.PublishedAt.Time
+	}
+	return backup
+}
+
+func newBackupEnclosure(enclosure database.Enclosure) *backupEnclosure {
+	backup := &backupEnclosure{
+		ID:        enclosure.ID,
+		CreatedAt: enclosure.CreatedAt,
+		UpdatedAt: enclosure.UpdatedAt,
+		PostID:    enclosure.PostID,
+		URL:       enclosure.Url,
+		MimeType:  enclosure.MimeType.String,
+	}
+	if enclosure.Length.Valid {
+		backup.Length = &enclosure.Length.Int64
+	}
+	if enclosure.DurationSeconds.Valid {
+		backup.DurationSeconds = &enclosure.DurationSeconds.Int32
+	}
+	return backup
+}
+
+func newBackupPostRevision(revision database.PostRevision) *backupPostRevision {
+	return &backupPostRevision{
+		ID:          revision.ID,
+		CreatedAt:   revision.CreatedAt,
+		PostID:      revision.PostID,
+		Title:       revision.Title,
+		URL:         revision.Url,
+		Description: revision.Description.String,
+		Content:     revision.Content.String,
+		ContentHash: revision.ContentHash.String,
+	}
+}
+
+func newBackupPostRead(read database.PostRead) *backupPostRead {
+	return &backupPostRead{
+		UserID: read.UserID,
+		PostID: read.PostID,
+		ReadAt: read.ReadAt,
+	}
+}
+
+func newBackupPostStar(star database.PostStar) *backupPostStar {
+	return &backupPostStar{
+		UserID:    star.UserID,
+		PostID:    star.PostID,
+		StarredAt: star.StarredAt,
+	}
+}
+
+// nullString turns a backed-up string back into a nullable column, "" meaning NULL:
+func nullString(value string) sql.NullString {
+	return sql.NullString{
+		String: value,
+		Valid:  value != "",
+	}
+}
@@ -1,6 +1,9 @@
 package main
 
-import "errors"
+import (
+	"errors"
+	"flag"
+)
 
 // Create a command struct. A command contains a name and a slice of string arguments. For example, 
 // in the case of the login command, the name would be "login" and the handler will expect the 
@@ -31,3 +34,21 @@ func (c *commands) run(s *state, cmd command) error {
 	}
 	return f(s, cmd)
 }
+
+// parseFlags parses a command's arguments with the given flag set. Unlike fs.Parse on its own,
+// flags and positional arguments can be mixed in any order (e.g. "browse 10 --full" as well
+// as "browse --full 10"). It returns the positional arguments:
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
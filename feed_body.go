@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// The compression schemes we can decode, sent as Accept-Encoding. Setting the header ourselves
+// turns off http.Transport's built-in gzip handling, so readFeedBody decodes all of them:
+const acceptEncoding = "br, gzip, deflate"
+
+// errNotAFeed is returned (wrapped) by fetchFeed when the URL points at something that isn't a
+// feed at all, like a web page or an image:
+var errNotAFeed = errors.New("not a feed")
+
+// checkContentType rejects responses that can't possibly be a feed, before we download them.
+// HTML gets the benefit of the doubt here, since some servers label their feeds text/html;
+// fetchFeed only gives up on it if it doesn't parse:
+func checkContentType(contentType string) error {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		// No (or a broken) Content-Type tells us nothing, so let the parser decide:
+		return nil
+	}
+	for _, prefix := range []string{"image/", "audio/", "video/", "font/"} {
+		if strings.HasPrefix(mediaType, prefix) {
+			return fmt.Errorf("%w: the server sent %s", errNotAFeed, mediaType)
+		}
+	}
+	switch mediaType {
+	case "application/pdf", "application/zip", "application/gzip", "application/x-gzip", "application/msword":
+		return fmt.Errorf("%w: the server sent %s", errNotAFeed, mediaType)
+	}
+	return nil
+}
+
+// isHTML reports whether the response claims to be a web page:
+func isHTML(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "text/html" || mediaType == "application/xhtml+xml")
+}
+
+// readFeedBody reads the whole response body, undoing any Content-Encoding, and fails rather
+// than read more than maxBytes. The limit applies to the decoded size, so a small compressed
+// response can't expand into something enormous either:
+func readFeedBody(resp *http.Response, maxBytes int64) ([]byte, error) {
+	if resp.ContentLength > maxBytes && resp.Header.Get("Content-Encoding") == "" {
+		return nil, fmt.Errorf("feed is too large: %d bytes (the limit is %d)", resp.ContentLength, maxBytes)
+	}
+
+	body, err := decodeContentEncoding(resp)
+	if err != nil {
+		return nil, err
+	}
+	// Read one byte past the limit, so we can tell a feed that's exactly maxBytes long from
+	// one that's longer:
+	dat, err := io.ReadAll(io.LimitReader(body, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(dat)) > maxBytes {
+		return nil, fmt.Errorf("feed is too large: more than %d bytes", maxBytes)
+	}
+	return dat, nil
+}
+
+// decodeContentEncoding wraps the response body in a decompressor matching its
+// Content-Encoding header:
+func decodeContentEncoding(resp *http.Response) (io.Reader, error) {
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	switch encoding {
+	case "", "identity":
+		return resp.Body, nil
+	case "gzip", "x-gzip":
+		reader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't decode gzip response: %w", err)
+		}
+		return reader, nil
+	case "br":
+		return brotli.NewReader(resp.Body), nil
+	case "deflate":
+		// "deflate" is meant to be zlib-wrapped, but plenty of servers send a raw deflate
+		// stream instead. A zlib stream starts with a two-byte header we can check for:
+		body := bufio.NewReader(resp.Body)
+		header, err := body.Peek(2)
+		if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+			reader, err := zlib.NewReader(body)
+			if err != nil {
+				return nil, fmt.Errorf("couldn't decode deflate response: %w", err)
+			}
+			return reader, nil
+		}
+		return flate.NewReader(body), nil
+	default:
+		return nil, fmt.Errorf("unsupported Content-Encoding: %s", encoding)
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+// FeedData is the format-independent view of a feed. Every parser (RSS, Atom, JSON Feed, ...) converts
+// its own document shape into this struct, so scrapeFeed only ever has to deal with one model:
+type FeedData struct {
+	Title       string
+	Link        string
+	Description string
+	// Schedule holds the publisher's hints about how often to fetch the feed (RSS only):
+	Schedule ScheduleHints
+	Items    []FeedItem
+}
+
+// FeedItem is a single post inside a FeedData:
+type FeedItem struct {
+	// GUID is the feed's own identifier for the item, if it has one (see postGUID):
+	GUID        string
+	Title       string
+	Link        string
+	Description string
+	// Content is the full body of the post, when the feed provides one separately from
+	// the (often truncated) description. It's HTML, so it isn't unescaped like the rest:
+	Content string
+	// PubDates holds every publication date the feed gave us for this item, most
+	// authoritative first (e.g. <pubDate>, then <dc:date>). parsePubDate uses the first
+	// one it can make sense of:
+	PubDates []string
+	// Enclosures are the media files attached to the item, e.g. a podcast episode:
+	Enclosures []FeedEnclosure
+}
+
+// FeedEnclosure is a media file attached to a FeedItem. Length and DurationSeconds are 0
+// when the feed doesn't say:
+type FeedEnclosure struct {
+	URL             string
+	MimeType        string
+	Length          int64
+	DurationSeconds int
+}
+
+// parseFeed works out which format the document is in, then unmarshals it with the matching
+// parser. JSON Feeds are recognised by their Content-Type (or a leading '{'); for XML we look
+// at the root element:
+	// <rss> is RSS 2.0
+	// <feed> is Atom 1.0
+	// <rdf:RDF> is RSS 1.0
+func parseFeed(dat []byte, contentType string) (*FeedData, error) {
+	if isJSONFeed(contentType, dat) {
+		var jsonFeed JSONFeed
+		if err := json.Unmarshal(bytes.TrimPrefix(dat, []byte("\xef\xbb\xbf")), &jsonFeed); err != nil {
+			return nil, err
+		}
+		return unescapeFeedData(jsonFeed.toFeedData()), nil
+	}
+
+	root, err := rootElement(dat, contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	var feedData *FeedData
+	switch root.Local {
+	case "rss":
+		var rssFeed RSSFeed
+		if err := decodeXML(dat, contentType, &rssFeed); err != nil {
+			return nil, err
+		}
+		feedData = rssFeed.toFeedData()
+	case "feed":
+		var atomFeed AtomFeed
+		if err := decodeXML(dat, contentType, &atomFeed); err != nil {
+			return nil, err
+		}
+		feedData = atomFeed.toFeedData()
+	case "RDF":
+		var rdfFeed RDFFeed
+		if err := decodeXML(dat, contentType, &rdfFeed); err != nil {
+			return nil, err
+		}
+		feedData = rdfFeed.toFeedData()
+	default:
+		return nil, fmt.Errorf("unsupported feed format: <%s>", root.Local)
+	}
+
+	return unescapeFeedData(feedData), nil
+}
+
+// unescapeFeedData cleans up the text fields of a parsed feed, whatever format it came from.
+func unescapeFeedData(feedData *FeedData) *FeedData {
+	// Use the html.UnescapeString function to decode escaped HTML entities (like &ldquo;).
+	// You'll need to run the Title and Description fields (of both the entire channel as well
+	// as the items) through this function:
+		// &ldquo; becomes "
+		// &rdquo; becomes "
+		// &amp; becomes &
+		// &#39; becomes '
+	feedData.Title = html.UnescapeString(feedData.Title)
+	feedData.Description = html.UnescapeString(feedData.Description)
+	for i, item := range feedData.Items {
+		item.Title = html.UnescapeString(item.Title)
+		item.Description = html.UnescapeString(item.Description)
+		feedData.Items[i] = item
+	}
+	return feedData
+}
+
+// rootElement returns the name of the first element in an XML document, skipping over the
+// XML declaration, comments and whitespace that may come before it:
+func rootElement(dat []byte, contentType string) (xml.Name, error) {
+	decoder, err := newXMLDecoder(dat, contentType)
+	if err != nil {
+		return xml.Name{}, err
+	}
+	for {
+		tok, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return xml.Name{}, errors.New("empty feed document")
+		}
+		if err != nil {
+			return xml.Name{}, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name, nil
+		}
+	}
+}
+
+// decodeXML unmarshals an XML feed document into v, like xml.Unmarshal but coping with
+// documents that aren't in UTF-8:
+func decodeXML(dat []byte, contentType string, v any) error {
+	decoder, err := newXMLDecoder(dat, contentType)
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(v)
+}
+
+// newXMLDecoder returns a decoder that converts the document to UTF-8 as it goes. Plenty of
+// older European and Japanese feeds are in ISO-8859-1, Windows-1252, Shift_JIS, KOI8-R and the
+// like, which encoding/xml refuses to read on its own. The character set comes from:
+	// the charset parameter of the HTTP Content-Type header, if there is one, which wins
+	// over anything the document says about itself
+	// otherwise the encoding in the XML declaration (<?xml version="1.0" encoding="...">)
+	// otherwise UTF-8, the XML default
+func newXMLDecoder(dat []byte, contentType string) (*xml.Decoder, error) {
+	if _, params, err := mime.ParseMediaType(contentType); err == nil && params["charset"] != "" {
+		utf8Reader, err := charset.NewReaderLabel(params["charset"], bytes.NewReader(dat))
+		if err != nil {
+			return nil, fmt.Errorf("couldn't decode feed: %w", err)
+		}
+		decoder := xml.NewDecoder(utf8Reader)
+		// The document is already UTF-8 by the time the decoder sees it, so whatever its
+		// XML declaration claims, there's nothing more to convert:
+		decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+			return input, nil
+		}
+		return decoder, nil
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(dat))
+	// The decoder calls this with the encoding from the XML declaration when it isn't UTF-8:
+	decoder.CharsetReader = charset.NewReaderLabel
+	return decoder, nil
+}
+
+// parseLength reads an enclosure's byte length attribute. Podcast feeds often leave it empty
+// or put garbage in it, in which case we treat the length as unknown:
+func parseLength(value string) int64 {
+	length, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || length < 0 {
+		return 0
+	}
+	return length
+}
+
+// parseITunesDuration reads an <itunes:duration>, which may be plain seconds ("3600"),
+// "MM:SS" or "HH:MM:SS". It returns 0 if the value can't be understood:
+func parseITunesDuration(value string) int {
+	parts := strings.Split(strings.TrimSpace(value), ":")
+	if len(parts) > 3 {
+		return 0
+	}
+	seconds := 0
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0
+		}
+		seconds = seconds*60 + n
+	}
+	return seconds
+}
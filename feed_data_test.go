@@ -0,0 +1,96 @@
+package main
+
+import "testing"
+
+// Feeds in the legacy encodings that still turn up in the wild, and what their titles should
+// come out as once decoded to UTF-8. The bytes are written out by hand, the way the server
+// sends them:
+func TestParseFeedCharsets(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		declaration string // the XML declaration's encoding, if any
+		title       string // the raw bytes of the title, in the feed's encoding
+		want        string
+	}{
+		{"UTF-8 by default", "application/rss+xml", "", "Caf\xc3\xa9 cr\xc3\xa8me", "Café crème"},
+		{"ISO-8859-1 declared", "application/rss+xml", "ISO-8859-1", "Caf\xe9 cr\xe8me", "Café crème"},
+		{"ISO-8859-1 from HTTP", "application/rss+xml; charset=ISO-8859-1", "", "Caf\xe9 cr\xe8me", "Café crème"},
+		{"latin1 alias", "text/xml", "latin1", "Stra\xdfe", "Straße"},
+		{"Windows-1252 declared", "application/rss+xml", "windows-1252", "\x93Quoted\x94 \x80 5 \x96 done", "“Quoted” € 5 – done"},
+		{"Windows-1252 from HTTP", "text/xml; charset=windows-1252", "", "\x93Quoted\x94 \x80 5", "“Quoted” € 5"},
+		{"Shift_JIS declared", "application/rss+xml", "Shift_JIS", "\x93\xfa\x96\x7b\x8c\xea", "日本語"},
+		{"Shift_JIS from HTTP", "text/xml; charset=Shift_JIS", "", "\x93\xfa\x96\x7b\x8c\xea", "日本語"},
+		{"KOI8-R declared", "application/rss+xml", "KOI8-R", "\xf0\xd2\xc9\xd7\xc5\xd4", "Привет"},
+		{"KOI8-R from HTTP", "application/xml; charset=koi8-r", "", "\xf0\xd2\xc9\xd7\xc5\xd4", "Привет"},
+		// The HTTP header wins over what the document claims about itself:
+		{"HTTP UTF-8 overrides declared ISO-8859-1", "application/rss+xml; charset=utf-8", "ISO-8859-1", "Caf\xc3\xa9", "Café"},
+		{"HTTP ISO-8859-1 overrides declared UTF-8", "application/rss+xml; charset=ISO-8859-1", "UTF-8", "Caf\xe9", "Café"},
+		{"HTTP KOI8-R overrides declared Windows-1252", "text/xml; charset=KOI8-R", "windows-1252", "\xf0\xd2\xc9\xd7\xc5\xd4", "Привет"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			feedData, err := parseFeed(rssDocument(test.declaration, test.title), test.contentType)
+			if err != nil {
+				t.Fatalf("parseFeed failed: %v", err)
+			}
+			if feedData.Title != test.want {
+				t.Errorf("feed title = %q, want %q", feedData.Title, test.want)
+			}
+			if len(feedData.Items) != 1 || feedData.Items[0].Title != test.want {
+				t.Errorf("items = %+v, want one titled %q", feedData.Items, test.want)
+			}
+		})
+	}
+}
+
+// A charset we don't know is an error, rather than a feed full of garbled text:
+func TestParseFeedUnknownCharset(t *testing.T) {
+	if _, err := parseFeed(rssDocument("", "Title"), "application/rss+xml; charset=x-made-up"); err == nil {
+		t.Error("parseFeed accepted an unknown HTTP charset")
+	}
+	if _, err := parseFeed(rssDocument("x-made-up", "Title"), "application/rss+xml"); err == nil {
+		t.Error("parseFeed accepted an unknown declared encoding")
+	}
+}
+
+// rssDocument builds a minimal RSS feed whose channel and only item are both called title:
+func rssDocument(encoding, title string) []byte {
+	declaration := `<?xml version="1.0"?>`
+	if encoding != "" {
+		declaration = `<?xml version="1.0" encoding="` + encoding + `"?>`
+	}
+	return []byte(declaration + "\n" +
+		`<rss version="2.0"><channel><title>` + title + `</title><link>https://example.com/</link>` +
+		`<item><title>` + title + `</title><link>https://example.com/1</link></item>` +
+		`</channel></rss>`)
+}
+
+// An <atom:link rel="self"> next to the channel's <link> mustn't replace it, whichever comes
+// first (Hugo's default template puts it second):
+func TestParseFeedRSSLink(t *testing.T) {
+	tests := []struct {
+		name    string
+		channel string
+	}{
+		{"atom:link after link", `<link>https://example.com/</link><atom:link href="https://example.com/index.xml" rel="self" type="application/rss+xml"/>`},
+		{"atom:link before link", `<atom:link href="https://example.com/index.xml" rel="self" type="application/rss+xml"/><link>https://example.com/</link>`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dat := []byte(`<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom"><channel><title>Blog</title>` + test.channel +
+				`<item><title>Post</title><link>https://example.com/post/</link>` +
+				`<atom:link href="https://example.com/post/index.xml" rel="self"/></item></channel></rss>`)
+			feedData, err := parseFeed(dat, "application/rss+xml")
+			if err != nil {
+				t.Fatalf("parseFeed failed: %v", err)
+			}
+			if feedData.Link != "https://example.com/" {
+				t.Errorf("feed link = %q, want https://example.com/", feedData.Link)
+			}
+			if len(feedData.Items) != 1 || feedData.Items[0].Link != "https://example.com/post/" {
+				t.Errorf("items = %+v, want one linking to https://example.com/post/", feedData.Items)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// Real-world feeds are very creative with their dates. Before parsing, parsePubDate rewrites
+// a date string into a canonical shape (no day name, English month abbreviations, numeric
+// zone offsets), so this list only has to cover the remaining differences in layout:
+var pubDateLayouts = []string{
+	// RFC 3339 / ISO 8601, used by Atom, JSON Feed and Dublin Core:
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+	// ISO 8601's basic format, without separators (iCalendar-style):
+	"20060102T150405Z0700",
+	"20060102T150405",
+	"20060102",
+	// RFC 822 / RFC 1123 and friends, used by RSS (day name already stripped):
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 -07:00",
+	"2 Jan 2006 15:04 -0700",
+	"2 Jan 06 15:04:05 -0700",
+	"2 Jan 06 15:04 -0700",
+	"2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04 MST",
+	"2 Jan 2006 15:04:05",
+	"2 Jan 2006 15:04",
+	"2 Jan 2006",
+	"2-Jan-06 15:04:05 -0700",
+	"2-Jan-2006 15:04:05 -0700",
+	"Jan 2 2006 15:04:05 -0700",
+	"Jan 2 2006 15:04:05",
+	"Jan 2 2006",
+	// ANSI C and Unix date(1) output:
+	"Jan 2 15:04:05 2006",
+	"Jan 2 15:04:05 -0700 2006",
+	"Jan 2 15:04:05 MST 2006",
+}
+
+// Go only understands zone abbreviations that belong to the local time zone; any other
+// abbreviation silently gets a zero offset. These are the ones that show up in feeds:
+var zoneOffsets = map[string]string{
+	"UT": "+0000", "UTC": "+0000", "GMT": "+0000", "Z": "+0000", "WET": "+0000",
+	"EST": "-0500", "EDT": "-0400", "CST": "-0600", "CDT": "-0500",
+	"MST": "-0700", "MDT": "-0600", "PST": "-0800", "PDT": "-0700",
+	"AKST": "-0900", "AKDT": "-0800", "HST": "-1000",
+	"BST": "+0100", "WEST": "+0100", "CET": "+0100", "CEST": "+0200", "MET": "+0100", "MEST": "+0200",
+	"EET": "+0200", "EEST": "+0300", "MSK": "+0300", "IST": "+0530",
+	"SGT": "+0800", "HKT": "+0800", "AWST": "+0800", "JST": "+0900", "KST": "+0900",
+	"ACST": "+0930", "ACDT": "+1030", "AEST": "+1000", "AEDT": "+1100",
+	"NZST": "+1200", "NZDT": "+1300",
+}
+
+// Month names that aren't the English three-letter abbreviation Go expects, keyed in lower
+// case without a trailing dot. Covers full English names plus the common European languages:
+var monthNames = map[string]string{
+	"january": "Jan", "february": "Feb", "march": "Mar", "april": "Apr", "june": "Jun",
+	"july": "Jul", "august": "Aug", "september": "Sep", "sept": "Sep", "october": "Oct",
+	"november": "Nov", "december": "Dec",
+	// German:
+	"januar": "Jan", "jän": "Jan", "februar": "Feb", "märz": "Mar", "mär": "Mar", "mai": "May",
+	"juni": "Jun", "juli": "Jul", "okt": "Oct", "oktober": "Oct", "dez": "Dec", "dezember": "Dec",
+	// French:
+	"janv": "Jan", "janvier": "Jan", "févr": "Feb", "fév": "Feb", "février": "Feb", "mars": "Mar",
+	"avr": "Apr", "avril": "Apr", "juin": "Jun", "juil": "Jul", "juillet": "Jul", "août": "Aug",
+	"déc": "Dec", "décembre": "Dec", "octobre": "Oct", "novembre": "Nov", "septembre": "Sep",
+	// Spanish, Italian and Portuguese:
+	"ene": "Jan", "enero": "Jan", "gen": "Jan", "abr": "Apr", "ago": "Aug", "dic": "Dec",
+	"set": "Sep", "ott": "Oct", "out": "Oct", "fev": "Feb", "mag": "May", "giu": "Jun",
+	"lug": "Jul",
+	// Dutch:
+	"mrt": "Mar", "mei": "May",
+}
+
+var englishMonths = map[string]bool{
+	"jan": true, "feb": true, "mar": true, "apr": true, "may": true, "jun": true,
+	"jul": true, "aug": true, "sep": true, "oct": true, "nov": true, "dec": true,
+}
+
+// English day names without a trailing comma (e.g. "Mon 02 Jan 2006") that need stripping:
+var dayNames = map[string]bool{
+	"mon": true, "tue": true, "wed": true, "thu": true, "fri": true, "sat": true, "sun": true,
+	"monday": true, "tuesday": true, "wednesday": true, "thursday": true, "friday": true,
+	"saturday": true, "sunday": true,
+}
+
+// parsePubDate tries each candidate date string in order (e.g. <pubDate>, then <dc:date>,
+// then <atom:updated>) and returns the first one that parses, converted to UTC:
+func parsePubDate(candidates ...string) (time.Time, bool) {
+	for _, candidate := range candidates {
+		value := normalizePubDate(candidate)
+		if value == "" {
+			continue
+		}
+		for _, layout := range pubDateLayouts {
+			if t, err := time.Parse(layout, value); err == nil {
+				return t.UTC(), true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// normalizePubDate rewrites the parts of a date string that vary between feeds so that the
+// layouts in pubDateLayouts can match it:
+// "Lundi, 2 juin 2025 10:00:00 CEST" becomes "2 Jun 2025 10:00:00 +0200"
+func normalizePubDate(value string) string {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return ""
+	}
+
+	// Drop a leading day name, in any language. RFC 822 puts a comma after it, which is
+	// the only reliable way to spot day names we don't know:
+	if strings.HasSuffix(fields[0], ",") && !startsWithDigit(fields[0]) {
+		fields = fields[1:]
+	} else if dayNames[strings.ToLower(strings.TrimSuffix(fields[0], "."))] {
+		fields = fields[1:]
+	}
+
+	// Drop RFC 822 comments, like the zone name in "10:00:00 +0000 (UTC)":
+	if n := len(fields); n > 1 && strings.HasPrefix(fields[n-1], "(") && strings.HasSuffix(fields[n-1], ")") {
+		fields = fields[:n-1]
+	}
+
+	for i, field := range fields {
+		// "Jan 2, 2006" -> "Jan 2 2006"
+		field = strings.TrimSuffix(field, ",")
+		lower := strings.ToLower(strings.TrimSuffix(field, "."))
+		if month, ok := monthNames[lower]; ok {
+			field = month
+		} else if englishMonths[lower] {
+			// Normalise the case of English abbreviations ("JAN", "jan.") too:
+			field = strings.ToUpper(lower[:1]) + lower[1:]
+		} else if offset, ok := zoneOffsets[strings.ToUpper(field)]; ok && i > 0 {
+			// Swap zone abbreviations (and "GMT+2"-style offsets) for numeric offsets:
+			field = offset
+		} else if offset, ok := parseGMTOffset(field); ok {
+			field = offset
+		}
+		fields[i] = field
+	}
+
+	return strings.Join(fields, " ")
+}
+
+// parseGMTOffset turns "GMT+2", "UTC-05:30" or "GMT+0100" into an RFC 822 offset like "+0200":
+func parseGMTOffset(value string) (string, bool) {
+	upper := strings.ToUpper(value)
+	var rest string
+	switch {
+	case strings.HasPrefix(upper, "GMT"):
+		rest = upper[3:]
+	case strings.HasPrefix(upper, "UTC"):
+		rest = upper[3:]
+	default:
+		return "", false
+	}
+	if len(rest) < 2 || (rest[0] != '+' && rest[0] != '-') {
+		return "", false
+	}
+	sign, digits := rest[:1], strings.ReplaceAll(rest[1:], ":", "")
+	switch len(digits) {
+	case 1:
+		digits = "0" + digits + "00"
+	case 2:
+		digits += "00"
+	case 3:
+		digits = "0" + digits
+	case 4:
+	default:
+		return "", false
+	}
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return "", false
+		}
+	}
+	return sign + digits, true
+}
+
+func startsWithDigit(value string) bool {
+	return value != "" && value[0] >= '0' && value[0] <= '9'
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// Date strings as they appear in real feeds, and the instant each one means:
+func TestParsePubDate(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string // RFC 3339, in UTC; "" when the input shouldn't parse
+	}{
+		// RFC 1123 / RFC 822, the RSS standard:
+		{"RFC1123 GMT", "Tue, 03 Jun 2025 10:00:00 GMT", "2025-06-03T10:00:00Z"},
+		{"RFC1123Z", "Tue, 03 Jun 2025 10:00:00 +0200", "2025-06-03T08:00:00Z"},
+		{"RFC1123Z negative offset", "Tue, 03 Jun 2025 10:00:00 -0700", "2025-06-03T17:00:00Z"},
+		{"offset with colon", "Tue, 03 Jun 2025 10:00:00 +02:00", "2025-06-03T08:00:00Z"},
+		{"single-digit day", "Tue, 3 Jun 2025 10:00:00 +0000", "2025-06-03T10:00:00Z"},
+		{"no day name", "03 Jun 2025 10:00:00 +0000", "2025-06-03T10:00:00Z"},
+		{"day name without comma", "Tue 03 Jun 2025 10:00:00 +0000", "2025-06-03T10:00:00Z"},
+		{"full day and month names", "Tuesday, 3 June 2025 10:00:00 +0000", "2025-06-03T10:00:00Z"},
+		{"upper-case month", "Tue, 03 JUN 2025 10:00:00 +0000", "2025-06-03T10:00:00Z"},
+		{"zone comment", "Tue, 03 Jun 2025 10:00:00 +0000 (UTC)", "2025-06-03T10:00:00Z"},
+
+		// Zone names, which Go only understands for the local zone:
+		{"EST", "Tue, 03 Jun 2025 10:00:00 EST", "2025-06-03T15:00:00Z"},
+		{"PDT", "Tue, 03 Jun 2025 10:00:00 PDT", "2025-06-03T17:00:00Z"},
+		{"CEST", "Tue, 03 Jun 2025 10:00:00 CEST", "2025-06-03T08:00:00Z"},
+		{"UT", "Tue, 03 Jun 2025 10:00:00 UT", "2025-06-03T10:00:00Z"},
+		{"lower-case zone", "Tue, 03 Jun 2025 10:00:00 gmt", "2025-06-03T10:00:00Z"},
+		{"GMT+2", "Tue, 03 Jun 2025 10:00:00 GMT+2", "2025-06-03T08:00:00Z"},
+		{"GMT-5", "Tue, 03 Jun 2025 10:00:00 GMT-5", "2025-06-03T15:00:00Z"},
+		{"UTC+05:30", "Tue, 03 Jun 2025 10:00:00 UTC+05:30", "2025-06-03T04:30:00Z"},
+		{"GMT+0100", "Tue, 03 Jun 2025 10:00:00 GMT+0100", "2025-06-03T09:00:00Z"},
+		// Like Go itself, we can only take an abbreviation we don't know as UTC:
+		{"unknown zone name", "Tue, 03 Jun 2025 10:00:00 XYZT", "2025-06-03T10:00:00Z"},
+
+		// Missing seconds, or no time at all:
+		{"no seconds", "Tue, 03 Jun 2025 10:00 +0000", "2025-06-03T10:00:00Z"},
+		{"no seconds with zone name", "Tue, 03 Jun 2025 10:00 EDT", "2025-06-03T14:00:00Z"},
+		{"date only", "03 Jun 2025", "2025-06-03T00:00:00Z"},
+
+		// Two-digit years (RFC 822 proper):
+		{"two-digit year", "Tue, 03 Jun 25 10:00:00 +0000", "2025-06-03T10:00:00Z"},
+		{"two-digit year without seconds", "Tue, 03 Jun 25 10:00 +0000", "2025-06-03T10:00:00Z"},
+		{"dashed two-digit year", "03-Jun-25 10:00:00 +0000", "2025-06-03T10:00:00Z"},
+
+		// RFC 3339 / ISO 8601, used by Atom and JSON Feed:
+		{"RFC3339 Z", "2025-06-03T10:00:00Z", "2025-06-03T10:00:00Z"},
+		{"RFC3339 offset", "2025-06-03T10:00:00+02:00", "2025-06-03T08:00:00Z"},
+		{"RFC3339 fractional seconds", "2025-06-03T10:00:00.123456Z", "2025-06-03T10:00:00.123456Z"},
+		{"ISO offset without colon", "2025-06-03T10:00:00+0200", "2025-06-03T08:00:00Z"},
+		{"ISO without seconds", "2025-06-03T10:00+02:00", "2025-06-03T08:00:00Z"},
+		{"ISO without zone", "2025-06-03T10:00:00", "2025-06-03T10:00:00Z"},
+		{"SQL-style", "2025-06-03 10:00:00", "2025-06-03T10:00:00Z"},
+		{"ISO date only", "2025-06-03", "2025-06-03T00:00:00Z"},
+		{"ISO basic format", "20250603T100000Z", "2025-06-03T10:00:00Z"},
+		{"surrounding whitespace", "\n  2025-06-03T10:00:00Z  \n", "2025-06-03T10:00:00Z"},
+
+		// Other languages:
+		{"German", "Di, 03 Juni 2025 10:00:00 +0200", "2025-06-03T08:00:00Z"},
+		{"German umlaut", "3 März 2025 10:00:00 +0100", "2025-03-03T09:00:00Z"},
+		{"French", "Mardi, 3 juin 2025 10:00:00 CEST", "2025-06-03T08:00:00Z"},
+		{"French abbreviation with dot", "3 févr. 2025 10:00:00 +0100", "2025-02-03T09:00:00Z"},
+		{"Spanish", "mar, 3 dic 2024 10:00:00 +0100", "2024-12-03T09:00:00Z"},
+		{"Italian", "3 ott 2024 10:00:00 +0200", "2024-10-03T08:00:00Z"},
+		{"Dutch", "3 mei 2025 10:00:00 +0200", "2025-05-03T08:00:00Z"},
+
+		// Other layouts:
+		{"US style", "June 3, 2025 10:00:00 +0000", "2025-06-03T10:00:00Z"},
+		{"ANSI C", "Tue Jun 3 10:00:00 2025", "2025-06-03T10:00:00Z"},
+		{"Unix date", "Tue Jun 3 10:00:00 UTC 2025", "2025-06-03T10:00:00Z"},
+
+		// Nothing usable:
+		{"empty", "", ""},
+		{"whitespace", "   ", ""},
+		{"day name only", "Tue,", ""},
+		{"words", "yesterday", ""},
+		{"invalid day", "Tue, 32 Jun 2025 10:00:00 +0000", ""},
+		{"invalid month", "Tue, 03 Foo 2025 10:00:00 +0000", ""},
+		{"time only", "10:00:00", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := parsePubDate(test.input)
+			if test.want == "" {
+				if ok {
+					t.Fatalf("parsePubDate(%q) = %v, want no match", test.input, got)
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("parsePubDate(%q) didn't parse (normalized to %q)", test.input, normalizePubDate(test.input))
+			}
+			if got.Location() != time.UTC {
+				t.Errorf("parsePubDate(%q) is in %v, want UTC", test.input, got.Location())
+			}
+			if formatted := got.Format(time.RFC3339Nano); formatted != test.want {
+				t.Errorf("parsePubDate(%q) = %s, want %s", test.input, formatted, test.want)
+			}
+		})
+	}
+}
+
+// The first candidate that parses wins, and unparseable ones are skipped:
+func TestParsePubDateCandidates(t *testing.T) {
+	got, ok := parsePubDate("", "not a date", "2025-06-03T10:00:00Z", "2024-01-01T00:00:00Z")
+	if !ok || !got.Equal(time.Date(2025, 6, 3, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("parsePubDate picked %v (ok=%v), want the third candidate", got, ok)
+	}
+	if _, ok := parsePubDate(); ok {
+		t.Error("parsePubDate() with no candidates parsed")
+	}
+}
+
+func TestNormalizePubDate(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"Lundi, 2 juin 2025 10:00:00 CEST", "2 Jun 2025 10:00:00 +0200"},
+		{"Tue, 03 Jun 2025 10:00:00 GMT", "03 Jun 2025 10:00:00 +0000"},
+		{"Tue 03 JUN 2025", "03 Jun 2025"},
+		{"June 3, 2025", "Jun 3 2025"},
+		{"Tue, 03 Jun 2025 10:00:00 GMT+2", "03 Jun 2025 10:00:00 +0200"},
+		{"Tue, 03 Jun 2025 10:00:00 +0000 (UTC)", "03 Jun 2025 10:00:00 +0000"},
+		{"3 févr. 2025", "3 Feb 2025"},
+		// A leading "Z" isn't a zone; only later fields are:
+		{"Z 2025", "Z 2025"},
+		{"2025-06-03T10:00:00Z", "2025-06-03T10:00:00Z"},
+		{"", ""},
+		{"  ", ""},
+	}
+	for _, test := range tests {
+		if got := normalizePubDate(test.input); got != test.want {
+			t.Errorf("normalizePubDate(%q) = %q, want %q", test.input, got, test.want)
+		}
+	}
+}
+
+func TestParseGMTOffset(t *testing.T) {
+	tests := []struct {
+		input  string
+		want   string
+		wantOK bool
+	}{
+		{"GMT+2", "+0200", true},
+		{"GMT-11", "-1100", true},
+		{"UTC+5:30", "+0530", true},
+		{"UTC-05:30", "-0530", true},
+		{"gmt+0100", "+0100", true},
+		{"GMT", "", false},
+		{"GMT+", "", false},
+		{"GMT2", "", false},
+		{"GMT+12345", "", false},
+		{"GMT+ab", "", false},
+		{"EST+2", "", false},
+	}
+	for _, test := range tests {
+		got, ok := parseGMTOffset(test.input)
+		if got != test.want || ok != test.wantOK {
+			t.Errorf("parseGMTOffset(%q) = %q, %v, want %q, %v", test.input, got, ok, test.want, test.wantOK)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+// feedCandidate is a feed we found while looking at a website:
+type feedCandidate struct {
+	URL   string
+	Title string
+	// Type is the MIME type the page advertised for the feed, if any:
+	Type string
+	// Feed is the parsed feed, when discovery had to download it anyway (nil otherwise):
+	Feed *FeedData
+}
+
+// The feed types a page can advertise with <link rel="alternate" type="...">:
+var feedLinkTypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/feed+json": true,
+	"application/rdf+xml":   true,
+}
+
+// Where blogs usually keep their feed, tried when the page doesn't link to one:
+// /feed and /rss (WordPress and most CMSes), /index.xml (Hugo), /feed.xml and /atom.xml
+// (Jekyll and friends), /rss.xml and /feed.json:
+var commonFeedPaths = []string{"/feed", "/rss", "/index.xml", "/feed.xml", "/atom.xml", "/rss.xml", "/feed.json"}
+
+// discoverFeeds finds the feeds behind a URL. People usually paste a blog's homepage rather
+// than its feed, so:
+	// if the URL is a feed already, that's the only candidate
+	// if it's a web page, every feed it advertises in a <link rel="alternate"> tag
+	// if it doesn't advertise any, whichever of commonFeedPaths turn out to be feeds
+func discoverFeeds(ctx context.Context, pageURL string, maxBytes int64) ([]feedCandidate, error) {
+	page, err := fetchPage(ctx, pageURL, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isHTML(page.ContentType) {
+		feedData, err := parseFeed(page.Body, page.ContentType)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s is neither a feed nor a web page", errNotAFeed, pageURL)
+		}
+		return []feedCandidate{{URL: pageURL, Title: feedData.Title, Feed: feedData}}, nil
+	}
+
+	// Relative links are relative to wherever we ended up after any redirects:
+	candidates, err := feedLinks(page.Body, page.ContentType, page.URL)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) > 0 {
+		return candidates, nil
+	}
+
+	for _, path := range commonFeedPaths {
+		probeURL := page.URL.ResolveReference(&url.URL{Path: path}).String()
+		result, err := fetchFeed(ctx, fetchRequest{URL: probeURL, MaxBytes: maxBytes})
+		if err != nil {
+			// Most of these will just be 404s:
+			continue
+		}
+		candidates = append(candidates, feedCandidate{URL: probeURL, Title: result.Feed.Title, Feed: result.Feed})
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("couldn't find a feed on %s", pageURL)
+	}
+	return candidates, nil
+}
+
+// fetchedPage is the response to a plain GET, before we know whether it's a feed or a page:
+type fetchedPage struct {
+	// URL is where the page was actually served from, after following any redirects:
+	URL         *url.URL
+	ContentType string
+	Body        []byte
+}
+
+// fetchPage downloads a URL that might be a web page or a feed. It uses the same headers and
+// limits as fetchFeed, but doesn't insist on the response being a feed:
+func fetchPage(ctx context.Context, pageURL string, maxBytes int64) (*fetchedPage, error) {
+	httpClient := http.Client{
+		Timeout: 10 * time.Second,
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "gator")
+	req.Header.Set("Accept", "text/html, application/xhtml+xml, application/rss+xml, application/atom+xml, application/feed+json, */*;q=0.8")
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &httpStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if err := checkContentType(contentType); err != nil {
+		return nil, err
+	}
+	body, err := readFeedBody(resp, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	return &fetchedPage{
+		URL:         resp.Request.URL,
+		ContentType: contentType,
+		Body:        body,
+	}, nil
+}
+
+// feedLinks returns the feeds an HTML page advertises, in the order it lists them, e.g.
+// <link rel="alternate" type="application/rss+xml" title="Posts" href="/feed.xml">
+func feedLinks(body []byte, contentType string, base *url.URL) ([]feedCandidate, error) {
+	// Work out the page's character set (from the header, a <meta> tag or a BOM), so titles
+	// in older non-UTF-8 pages come out readable:
+	utf8Reader, err := charset.NewReader(bytes.NewReader(body), contentType)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't decode page: %w", err)
+	}
+
+	var candidates []feedCandidate
+	seen := map[string]bool{}
+	tokenizer := html.NewTokenizer(utf8Reader)
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			if errors.Is(tokenizer.Err(), io.EOF) {
+				return candidates, nil
+			}
+			return nil, tokenizer.Err()
+		}
+		if tokenType != html.StartTagToken && tokenType != html.SelfClosingTagToken {
+			continue
+		}
+
+		token := tokenizer.Token()
+		attrs := map[string]string{}
+		for _, attr := range token.Attr {
+			attrs[strings.ToLower(attr.Key)] = strings.TrimSpace(attr.Val)
+		}
+		switch token.Data {
+		case "base":
+			// <base href> changes what relative links are relative to:
+			if href, err := base.Parse(attrs["href"]); err == nil && attrs["href"] != "" {
+				base = href
+			}
+		case "link":
+			linkType := strings.ToLower(attrs["type"])
+			if !hasToken(attrs["rel"], "alternate") || !feedLinkTypes[linkType] || attrs["href"] == "" {
+				continue
+			}
+			href, err := base.Parse(attrs["href"])
+			if err != nil || seen[href.String()] {
+				continue
+			}
+			seen[href.String()] = true
+			candidates = append(candidates, feedCandidate{
+				URL:   href.String(),
+				Title: attrs["title"],
+				Type:  linkType,
+			})
+		}
+	}
+}
+
+// hasToken reports whether a space-separated attribute like rel="alternate nofollow"
+// contains the given token, ignoring case:
+func hasToken(value, token string) bool {
+	for _, field := range strings.Fields(value) {
+		if strings.EqualFold(field, token) {
+			return true
+		}
+	}
+	return false
+}
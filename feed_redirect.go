@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"gator/internal/database"
+)
+
+// How many fetches in a row have to be permanently redirected to the same URL before we move
+// the feed there. One 301 could be a misconfigured server having a bad day; a few in a row
+// means the feed really has moved:
+const redirectsBeforeMove = 3
+
+// followPermanentRedirect keeps track of a feed's permanent redirects after a successful
+// fetch, and moves the feed to its new URL once it has consistently been redirected there.
+// permanentURL is fetchResult.PermanentURL, which is empty if there was no redirect:
+func followPermanentRedirect(ctx context.Context, db *database.Queries, feed database.Feed, permanentURL string) {
+	if permanentURL == "" || permanentURL == feed.Url {
+		if err := db.ClearFeedRedirect(ctx, feed.ID); err != nil {
+			log.Printf("Couldn't clear redirect of feed %s: %v", feed.Name, err)
+		}
+		return
+	}
+
+	redirected, err := db.RecordFeedRedirect(ctx, database.RecordFeedRedirectParams{
+		ID:          feed.ID,
+		RedirectUrl: permanentURL,
+	})
+	if err != nil {
+		log.Printf("Couldn't record redirect of feed %s: %v", feed.Name, err)
+		return
+	}
+	if redirected.RedirectCount < redirectsBeforeMove {
+		return
+	}
+
+	// This fails if another feed already has the new URL; we leave both alone in that case
+	// rather than guess which one to keep:
+	moved, err := db.MoveFeedURL(ctx, database.MoveFeedURLParams{
+		ID:  feed.ID,
+		Url: permanentURL,
+	})
+	if err != nil {
+		log.Printf("Couldn't move feed %s to %s: %v", feed.Name, permanentURL, err)
+		return
+	}
+	log.Printf("Feed %s has moved from %s to %s", moved.Name, feed.Url, moved.Url)
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"gator/internal/database"
+)
+
+// How many of a feed's latest posts the adaptive scheduler looks at to work out how often the
+// feed publishes. Enough to smooth out a burst of posts, few enough to notice a change of pace:
+const schedulePostSample = 20
+
+// ScheduleHints are what a feed tells us about when it's worth fetching it. RSS 2.0 has
+// <ttl> (minutes to cache the feed for), <skipHours> and <skipDays>; the other formats have
+// nothing equivalent, so their hints are always empty:
+type ScheduleHints struct {
+	TTL time.Duration
+	// SkipHours are hours of the day (0-23), in GMT:
+	SkipHours []int
+	SkipDays  []time.Weekday
+}
+
+// parseScheduleHints reads the raw RSS channel elements, ignoring any values that don't make
+// sense rather than rejecting the whole feed over them:
+func parseScheduleHints(ttl string, skipHours, skipDays []string) ScheduleHints {
+	var hints ScheduleHints
+	if minutes, err := strconv.Atoi(strings.TrimSpace(ttl)); err == nil && minutes > 0 {
+		hints.TTL = time.Duration(minutes) * time.Minute
+	}
+	for _, value := range skipHours {
+		hour, err := strconv.Atoi(strings.TrimSpace(value))
+		// The spec says 0-23, but some feeds count 1-24; 24 can only mean midnight:
+		if err == nil && hour == 24 {
+			hour = 0
+		}
+		if err == nil && hour >= 0 && hour < 24 {
+			hints.SkipHours = append(hints.SkipHours, hour)
+		}
+	}
+	for _, value := range skipDays {
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			if strings.EqualFold(strings.TrimSpace(value), day.String()) {
+				hints.SkipDays = append(hints.SkipDays, day)
+			}
+		}
+	}
+	return hints
+}
+
+// scheduleHintsFromFeed reads back the hints we stored for a feed the last time it gave us
+// a full response (see UpdateFeedScheduleHints):
+func scheduleHintsFromFeed(feed database.Feed) ScheduleHints {
+	hours := make([]string, len(feed.SkipHours))
+	for i, hour := range feed.SkipHours {
+		hours[i] = strconv.Itoa(int(hour))
+	}
+	hints := parseScheduleHints("", hours, feed.SkipDays)
+	if feed.TtlMinutes.Valid {
+		hints.TTL = time.Duration(feed.TtlMinutes.Int32) * time.Minute
+	}
+	return hints
+}
+
+// skips reports whether the publisher asked us not to fetch at time t:
+func (hints ScheduleHints) skips(t time.Time) bool {
+	t = t.UTC()
+	for _, hour := range hints.SkipHours {
+		if t.Hour() == hour {
+			return true
+		}
+	}
+	for _, day := range hints.SkipDays {
+		if t.Weekday() == day {
+			return true
+		}
+	}
+	return false
+}
+
+// adaptiveInterval estimates how long to wait before fetching a feed again from when its
+// recent posts were published (newest first). We aim to poll about twice per expected post,
+// so a new post shows up within half a gap of being published:
+// - a feed that posts every hour gets fetched every 30 minutes
+// - a feed that's gone quiet for longer than its usual gap backs off with the silence
+// - a feed without enough history to judge gets the default interval
+// The result always lies between opts.minInterval and opts.maxInterval:
+func adaptiveInterval(now time.Time, publishTimes []time.Time, opts aggOptions) time.Duration {
+	interval := opts.defaultInterval
+	if len(publishTimes) >= 2 {
+		newest, oldest := publishTimes[0], publishTimes[len(publishTimes)-1]
+		gap := newest.Sub(oldest) / time.Duration(len(publishTimes)-1)
+		if silence := now.Sub(newest); silence > gap {
+			gap = silence
+		}
+		interval = gap / 2
+	}
+	return min(max(interval, opts.minInterval), opts.maxInterval)
+}
+
+// nextFetchDelay works out how long from now a feed should next be fetched. A manual interval
+// (see setinterval) always wins over the adaptive estimate, but the publisher's own hints are
+// honoured either way: never sooner than <ttl>, and never during <skipHours>/<skipDays>:
+func nextFetchDelay(now time.Time, feed database.Feed, hints ScheduleHints, publishTimes []time.Time, opts aggOptions) time.Duration {
+	interval := adaptiveInterval(now, publishTimes, opts)
+	if feed.FetchIntervalSeconds.Valid {
+		interval = time.Duration(feed.FetchIntervalSeconds.Int32) * time.Second
+	}
+	interval = max(interval, hints.TTL)
+
+	// Step forward to the top of each skipped hour until we land on one we're allowed to
+	// fetch in. A week of hours covers every combination, even a feed that skips them all:
+	next := now.Add(interval)
+	for i := 0; i < 7*24 && hints.skips(next); i++ {
+		next = next.Truncate(time.Hour).Add(time.Hour)
+	}
+	return next.Sub(now)
+}
+
+// scheduleNextFetch replaces the fixed next_fetch_at that ClaimFeedsToFetch gave a feed with
+// one based on the feed's publishing history and hints:
+func scheduleNextFetch(ctx context.Context, db *database.Queries, feed database.Feed, hints ScheduleHints, opts aggOptions) {
+	rows, err := db.GetRecentPublishTimesForFeed(ctx, database.GetRecentPublishTimesForFeedParams{
+		FeedID: feed.ID,
+		Limit:  schedulePostSample,
+	})
+	if err != nil {
+		log.Printf("Couldn't get publishing history for feed %s: %v", feed.Name, err)
+		return
+	}
+	var publishTimes []time.Time
+	for _, row := range rows {
+		publishTimes = append(publishTimes, row.Time)
+	}
+
+	delay := nextFetchDelay(time.Now().UTC(), feed, hints, publishTimes, opts)
+	err = db.SetFeedNextFetch(ctx, database.SetFeedNextFetchParams{
+		ID:           feed.ID,
+		DelaySeconds: delay.Seconds(),
+	})
+	if err != nil {
+		log.Printf("Couldn't schedule feed %s: %v", feed.Name, err)
+	}
+}
+
+// saveScheduleHints stores the hints from a full response, so they still apply after a 304:
+func saveScheduleHints(ctx context.Context, db *database.Queries, feed database.Feed, hints ScheduleHints) {
+	skipHours := make([]int32, len(hints.SkipHours))
+	for i, hour := range hints.SkipHours {
+		skipHours[i] = int32(hour)
+	}
+	skipDays := make([]string, len(hints.SkipDays))
+	for i, day := range hints.SkipDays {
+		skipDays[i] = day.String()
+	}
+	err := db.UpdateFeedScheduleHints(ctx, database.UpdateFeedScheduleHintsParams{
+		ID: feed.ID,
+		TtlMinutes: sql.NullInt32{
+			Int32: int32(hints.TTL / time.Minute),
+			Valid: hints.TTL > 0,
+		},
+		SkipHours: skipHours,
+		SkipDays:  skipDays,
+	})
+	if err != nil {
+		log.Printf("Couldn't save schedule hints for feed %s: %v", feed.Name, err)
+	}
+}
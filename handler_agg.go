@@ -3,79 +3,378 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
-	"strings"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"gator/internal/database"
 	"github.com/google/uuid"
 )
+// aggOptions controls how the agg command schedules and spreads out its work:
+type aggOptions struct {
+	// defaultInterval is how often a feed is fetched if it doesn't have its own interval
+	defaultInterval time.Duration
+	// concurrency is the number of feeds fetched in parallel
+	concurrency int
+	// batchSize is the number of feeds claimed from the database at a time
+	batchSize int
+	// minInterval and maxInterval bound the adaptive schedule (see adaptiveInterval)
+	minInterval time.Duration
+	maxInterval time.Duration
+	// maxFailures is how many fetches in a row can fail before a feed is disabled (0 = never)
+	maxFailures int
+	// maxFeedBytes is the largest feed we'll download (max_feed_bytes in the config file)
+	maxFeedBytes int64
+}
+
 // Update the agg command to now take a single argument: time_between_reqs:
 /* time_between_reqs is a duration string, like 1s, 1m, 1h, etc. I used the time.ParseDuration 
 function to parse it into a time.Duration value */
+// Each feed has its own schedule (see setinterval), so time_between_reqs is now just how
+// often agg checks for feeds that are due; every due feed is fetched on each check.
+// --concurrency and --batch control how many feeds are fetched in parallel and claimed at
+// a time, and --default-interval is the schedule for feeds without one of their own until
+// they have enough posts to learn from; after that their schedule adapts to how often they
+// publish, between --min-interval and --max-interval.
+// A feed that fails to fetch is retried with exponential backoff, and disabled after
+// --max-failures failures in a row (see feedstatus and enablefeed).
+// --once fetches every due feed a single time and exits (for cron).
+// SIGINT/SIGTERM stop agg cleanly: in-flight fetches are cancelled, the workers drain, and
+// any claimed feed that didn't get fetched is handed back:
 func handlerAgg(s *state, cmd command) error {
-	if len(cmd.Args) < 1 || len(cmd.Args) > 2 {
-		return fmt.Errorf("usage: %v <time_between_reqs>", cmd.Name)
+	fs := flag.NewFlagSet(cmd.Name, flag.ContinueOnError)
+	concurrency := fs.Int("concurrency", 1, "number of feeds to fetch in parallel")
+	batchSize := fs.Int("batch", 0, "number of feeds to claim at a time (defaults to --concurrency)")
+	defaultInterval := fs.Duration("default-interval", time.Hour, "how often to fetch feeds that don't have their own interval")
+	minInterval := fs.Duration("min-interval", 10*time.Minute, "never fetch a feed more often than this, however often it posts")
+	maxInterval := fs.Duration("max-interval", 24*time.Hour, "always fetch a feed at least this often, however quiet it is")
+	maxFailures := fs.Int("max-failures", 10, "disable a feed after this many failed fetches in a row (0 = never)")
+	once := fs.Bool("once", false, "fetch every due feed once, then exit")
+	args, err := parseFlags(fs, cmd.Args)
+	if err != nil || (*once && len(args) != 0) || (!*once && len(args) != 1) {
+		return fmt.Errorf("usage: %v <time_between_reqs> [--concurrency n] [--batch n] [--default-interval d] [--min-interval d] [--max-interval d] [--max-failures n] | %v --once", cmd.Name, cmd.Name)
+	}
+
+	if *concurrency < 1 {
+		return fmt.Errorf("concurrency must be at least 1")
+	}
+	if *batchSize == 0 {
+		*batchSize = *concurrency
+	}
+	if *batchSize < 1 {
+		return fmt.Errorf("batch size must be at least 1")
+	}
+	if *defaultInterval < time.Second || *defaultInterval > maxFetchInterval {
+		return fmt.Errorf("default interval must be between 1s and %s", maxFetchInterval)
+	}
+	if *minInterval < time.Second || *maxInterval < *minInterval || *maxInterval > maxFetchInterval {
+		return fmt.Errorf("intervals must satisfy 1s <= min-interval <= max-interval <= %s", maxFetchInterval)
+	}
+	if *maxFailures < 0 {
+		return fmt.Errorf("max failures can't be negative")
+	}
+	opts := aggOptions{
+		defaultInterval: *defaultInterval,
+		concurrency:     *concurrency,
+		batchSize:       *batchSize,
+		minInterval:     *minInterval,
+		maxInterval:     *maxInterval,
+		maxFailures:     *maxFailures,
+		maxFeedBytes:    s.cfg.FeedSizeLimit(),
+	}
+
+	// ctx is cancelled on Ctrl-C or SIGTERM. Once that happens we stop listening, so a
+	// second Ctrl-C kills the process straight away if draining takes too long:
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	// In one-shot mode, everything that's due right now is fetched exactly once. Feeds we
+	// fetch are rescheduled into the future, and a feed that comes due again while we're
+	// still running is after our cutoff, so it waits for the next run:
+	if *once {
+		total, err := scrapeDueFeeds(ctx, s, opts, time.Now())
+		if err != nil {
+			return fmt.Errorf("couldn't claim feeds to fetch: %w", err)
+		}
+		if ctx.Err() != nil {
+			log.Printf("Interrupted after fetching %d feeds", total)
+			return nil
+		}
+		log.Printf("Fetched %d feeds", total)
+		return nil
 	}
 
-	timeBetweenRequests, err := time.ParseDuration(cmd.Args[0])
+	timeBetweenRequests, err := time.ParseDuration(args[0])
 	if err != nil {
 		return fmt.Errorf("invalid duration: %w", err)
 	}
+	if timeBetweenRequests <= 0 {
+		return fmt.Errorf("time_between_reqs must be positive")
+	}
+
 	// It should print a message like Collecting feeds every 1m0s when it starts:
-	log.Printf("Collecting feeds every %s...", timeBetweenRequests)
+	log.Printf("Checking for due feeds every %s with %d workers...", timeBetweenRequests, opts.concurrency)
 	// Use a time.Ticker to run your scrapeFeeds function once every time_between_reqs. I used a 
 	// for loop to ensure that it runs immediately (I don't like waiting) and then every time the 
 	// ticker ticks:
 	ticker := time.NewTicker(timeBetweenRequests)
+	defer ticker.Stop()
+
+	for {
+		if _, err := scrapeDueFeeds(ctx, s, opts, time.Now()); err != nil {
+			log.Println("Couldn't claim feeds to fetch", err)
+		}
+		select {
+		case <-ctx.Done():
+			log.Println("Shutting down...")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
 
-	for ; ; <-ticker.C {
-		scrapeFeeds(s)
+// scrapeDueFeeds works through every feed that was due at dueBefore, one batch at a time,
+// and returns how many it claimed. It stops early if ctx is cancelled:
+func scrapeDueFeeds(ctx context.Context, s *state, opts aggOptions, dueBefore time.Time) (int, error) {
+	total := 0
+	for ctx.Err() == nil {
+		claimed, err := scrapeFeeds(ctx, s, opts, dueBefore)
+		if err != nil {
+			return total, err
+		}
+		total += claimed
+		// A short batch means there's nothing else due:
+		if claimed < opts.batchSize {
+			break
+		}
 	}
+	return total, nil
 }
+
 // Write an aggregation function, I called mine scrapeFeeds
-func scrapeFeeds(s *state) {
-	// Get the next feed to fetch from the DB:
-	feed, err := s.db.GetNextFeedToFetch(context.Background())
+// It claims the next batch of due feeds and hands them to a bounded pool of workers, so at
+// most opts.concurrency fetches are in flight at once. Claiming marks the feeds fetched in the
+// same statement that selects them, so any number of agg processes can share a database
+// without fetching the same feed twice.
+// It returns the number of feeds it claimed, and waits for all of them before returning:
+func scrapeFeeds(ctx context.Context, s *state, opts aggOptions, dueBefore time.Time) (int, error) {
+	// Claim the next feeds to fetch from the DB:
+	claims, err := s.db.ClaimFeedsToFetch(ctx, database.ClaimFeedsToFetchParams{
+		DefaultIntervalSeconds: int32(opts.defaultInterval.Seconds()),
+		DueBefore:              dueBefore,
+		BatchSize:              int32(opts.batchSize),
+	})
 	if err != nil {
-		log.Println("Couldn't get next feeds to fetch", err)
-		return
+		return 0, err
+	}
+	if len(claims) == 0 {
+		return 0, nil
+	}
+	log.Printf("Claimed %d feeds to fetch!", len(claims))
+
+	jobs := make(chan database.ClaimFeedsToFetchRow)
+	var wg sync.WaitGroup
+	for i := 0; i < min(opts.concurrency, len(claims)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for claim := range jobs {
+				err := scrapeFeed(ctx, s.db, claim.Feed, opts)
+				// A fetch cut short by shutdown doesn't count: hand the feed back so the
+				// next run picks it up first:
+				if err != nil && ctx.Err() != nil {
+					releaseFeedClaim(s.db, claim)
+					continue
+				}
+				if err != nil {
+					log.Printf("Couldn't collect feed %s: %v", claim.Feed.Name, err)
+					recordFeedFailure(s.db, claim.Feed, err, opts)
+				}
+			}
+		}()
+	}
+dispatch:
+	for i, claim := range claims {
+		select {
+		case jobs <- claim:
+		case <-ctx.Done():
+			// Shutting down: none of the remaining feeds has been started, so release them all:
+			for _, unstarted := range claims[i:] {
+				releaseFeedClaim(s.db, unstarted)
+			}
+			break dispatch
+		}
 	}
-	log.Println("Found a feed to fetch!")
-	scrapeFeed(s.db, feed)
+	close(jobs)
+	// Wait for every feed in the batch before the next tick picks up more:
+	wg.Wait()
+	return len(claims), nil
 }
 
-func scrapeFeed(db *database.Queries, feed database.Feed) {
-	// Mark the feed as fetched:
-	_, err := db.MarkFeedFetched(context.Background(), feed.ID)
+// releaseFeedClaim undoes ClaimFeedsToFetch for a feed we didn't get to fetch. It runs during
+// shutdown, after the agg context has been cancelled, so it uses a fresh context:
+func releaseFeedClaim(db *database.Queries, claim database.ClaimFeedsToFetchRow) {
+	err := db.ReleaseFeedClaim(context.Background(), database.ReleaseFeedClaimParams{
+		ID:            claim.Feed.ID,
+		LastFetchedAt: claim.PreviousFetchedAt,
+		NextFetchAt:   claim.PreviousNextFetchAt,
+	})
 	if err != nil {
-		log.Printf("Couldn't mark feed %s fetched: %v", feed.Name, err)
+		log.Printf("Couldn't release feed %s: %v", claim.Feed.Name, err)
 		return
 	}
-	// Fetch the feed using the URL:
-	feedData, err := fetchFeed(context.Background(), feed.Url)
+	log.Printf("Released feed %s for the next run", claim.Feed.Name)
+}
+
+// recordFeedFailure notes a failed fetch against the feed, which pushes its next fetch back
+// and may disable it (see RecordFeedFailure):
+func recordFeedFailure(db *database.Queries, feed database.Feed, fetchErr error, opts aggOptions) {
+	// Remember the HTTP status when the server gave us one, e.g. to tell a 404 from a 503:
+	status := sql.NullInt32{}
+	var statusErr *httpStatusError
+	if errors.As(fetchErr, &statusErr) {
+		status = sql.NullInt32{
+			Int32: int32(statusErr.StatusCode),
+			Valid: true,
+		}
+	}
+	updated, err := db.RecordFeedFailure(context.Background(), database.RecordFeedFailureParams{
+		ID: feed.ID,
+		LastError: sql.NullString{
+			String: fetchErr.Error(),
+			Valid:  true,
+		},
+		LastHttpStatus:         status,
+		DefaultIntervalSeconds: int32(opts.defaultInterval.Seconds()),
+		MaxBackoffSeconds:      int32(opts.maxInterval.Seconds()),
+		MaxFailures:            int32(opts.maxFailures),
+	})
 	if err != nil {
-		log.Printf("Couldn't collect feed %s: %v", feed.Name, err)
+		log.Printf("Couldn't record failure of feed %s: %v", feed.Name, err)
 		return
 	}
+	if updated.DisabledAt.Valid {
+		log.Printf("Disabled feed %s after %d failures in a row", feed.Name, updated.ConsecutiveFailures)
+	}
+}
+
+// scrapeFeed fetches a feed that has already been claimed (and so marked fetched) by
+// ClaimFeedsToFetch, and stores its posts. It returns an error if the feed couldn't be
+// fetched at all; problems with individual posts are only logged.
+// After a successful fetch the feed is rescheduled by scheduleNextFetch:
+func scrapeFeed(ctx context.Context, db *database.Queries, feed database.Feed, opts aggOptions) error {
+	// Fetch the feed using the URL, sending back the validators from the last fetch so an
+	// unchanged feed costs us a 304 instead of the whole document:
+	result, err := fetchFeed(ctx, fetchRequest{
+		URL:          feed.Url,
+		ETag:         feed.Etag.String,
+		LastModified: feed.LastModified.String,
+		MaxBytes:     opts.maxFeedBytes,
+	})
+	if err != nil {
+		return err
+	}
+	// Once the feed is downloaded, finish storing it even if we're asked to shut down
+	// part-way through, so we never end up with a fetched feed that's missing posts:
+	storeCtx := context.WithoutCancel(ctx)
+	err = db.RecordFeedSuccess(storeCtx, database.RecordFeedSuccessParams{
+		ID: feed.ID,
+		LastHttpStatus: sql.NullInt32{
+			Int32: int32(result.StatusCode),
+			Valid: true,
+		},
+	})
+	if err != nil {
+		log.Printf("Couldn't record success of feed %s: %v", feed.Name, err)
+	}
+	// The feed may have moved; if it has for good, start using its new URL:
+	followPermanentRedirect(storeCtx, db, feed, result.PermanentURL)
+	// The feed is already marked fetched, so a 304 only needs rescheduling, using the
+	// hints we saved from its last full response:
+	if result.NotModified {
+		log.Printf("Feed %s not modified since last fetch", feed.Name)
+		scheduleNextFetch(storeCtx, db, feed, scheduleHintsFromFeed(feed), opts)
+		return nil
+	}
+	feedData := result.Feed
+
 	// Update your scraper to save posts. Instead of printing out the 
 	// titles of the posts, save them to the database!:
-	for _, item := range feedData.Channel.Item {
+	newPosts, updatedPosts, failedPosts := 0, 0, 0
+	for _, item := range feedData.Items {
 		// Make sure that you're parsing the "published at" time properly 
 		// from the feeds. Sometimes they might be in a different format than 
 		// you expect, so you might need to handle that:
 		// (You may have to manually convert the data into database/sql types)
-		publishedAt := sql.NullTime{}
-		if t, err := time.Parse(time.RFC1123Z, item.PubDate); err == nil {
-			publishedAt = sql.NullTime{
-				Time:  t,
-				Valid: true,
+		// If none of the item's dates can be parsed, fall back to the time we first saw it,
+		// so the post still sorts sensibly in browse instead of sinking to the bottom:
+		// (The post remembers that its date was inferred, so the adaptive schedule ignores it.)
+		publishedAt, dated := parsePubDate(item.PubDates...)
+		if !dated {
+			publishedAt = time.Now().UTC()
+		}
+
+		guid := postGUID(item)
+		legacyGUID := legacyPostGUID(item)
+		// Feeds often keep listing posts long after prune has deleted them. Those stay deleted:
+		pruned, err := db.IsPostPruned(storeCtx, database.IsPostPrunedParams{
+			FeedID:     feed.ID,
+			Guid:       guid,
+			LegacyGuid: legacyGUID,
+		})
+		if err != nil {
+			log.Printf("Couldn't check whether post %s was pruned: %v", item.Link, err)
+			failedPosts++
+			continue
+		}
+		if pruned {
+			continue
+		}
+		// A post we stored under its link before we kept GUIDs becomes this one:
+		if legacyGUID != "" {
+			err = db.AdoptLegacyPost(storeCtx, database.AdoptLegacyPostParams{
+				Guid:       guid,
+				FeedID:     feed.ID,
+				LegacyGuid: legacyGUID,
+			})
+			if err != nil {
+				log.Printf("Couldn't adopt earlier copy of post %s: %v", item.Link, err)
+				failedPosts++
+				continue
 			}
 		}
+		hash := sql.NullString{
+			String: contentHash(item),
+			Valid:  true,
+		}
+		// If the author has edited a post we already have, keep the old version around
+		// before it gets overwritten (this is a no-op for new or unchanged posts):
+		err = db.CreatePostRevision(storeCtx, database.CreatePostRevisionParams{
+			ID:          uuid.New(),
+			CreatedAt:   time.Now().UTC(),
+			FeedID:      feed.ID,
+			Guid:        guid,
+			ContentHash: hash,
+		})
+		if err != nil {
+			log.Printf("Couldn't save revision of post %s: %v", item.Link, err)
+			failedPosts++
+			continue
+		}
 
-		_, err = db.CreatePost(context.Background(), database.CreatePostParams{
-			ID:        uuid.New(),
+		postID := uuid.New()
+		post, err := db.UpsertPost(storeCtx, database.UpsertPostParams{
+			ID:        postID,
 			CreatedAt: time.Now().UTC(),
 			UpdatedAt: time.Now().UTC(),
 			FeedID:    feed.ID,
@@ -84,16 +383,116 @@ func scrapeFeed(db *database.Queries, feed database.Feed) {
 				String: item.Description,
 				Valid:  true,
 			},
-			Url:         item.Link,
-			PublishedAt: publishedAt,
+			Url: item.Link,
+			// Only store a body when the feed actually gave us one:
+			Content: sql.NullString{
+				String: item.Content,
+				Valid:  item.Content != "",
+			},
+			PublishedAt: sql.NullTime{
+				Time:  publishedAt,
+				Valid: true,
+			},
+			PublishedAtInferred: !dated,
+			Guid:                guid,
+			ContentHash:         hash,
 		})
-		if err != nil {
-			if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+		switch {
+		// UpsertPost does nothing (and returns no row) when the post is already stored and
+		// hasn't changed. Its enclosures still need checking, since they aren't part of the
+		// content hash, so look it up:
+		case errors.Is(err, sql.ErrNoRows):
+			post, err = db.GetPostByFeedAndGUID(storeCtx, database.GetPostByFeedAndGUIDParams{
+				FeedID: feed.ID,
+				Guid:   guid,
+			})
+			if err != nil {
+				log.Printf("Couldn't get post %s: %v", item.Link, err)
+				failedPosts++
 				continue
 			}
-			log.Printf("Couldn't create post: %v", err)
+		case err != nil:
+			log.Printf("Couldn't save post: %v", err)
+			failedPosts++
 			continue
+		// An update keeps the existing row's ID, so only a brand new post has ours:
+		case post.ID == postID:
+			newPosts++
+		default:
+			updatedPosts++
+		}
+		if !saveEnclosures(storeCtx, db, post, item.Enclosures) {
+			failedPosts++
 		}
 	}
-	log.Printf("Feed %s collected, %v posts found", feed.Name, len(feedData.Channel.Item))
-}
\ No newline at end of file
+	// Only remember the validators once the posts are stored, so a failed run doesn't turn
+	// into a 304 next time. If any post couldn't be stored, forget them instead, so the next
+	// fetch downloads the whole feed again and has another go at it:
+	etag, lastModified := result.ETag, result.LastModified
+	if failedPosts > 0 {
+		log.Printf("Couldn't store %d posts of feed %s; it will be downloaded in full next time", failedPosts, feed.Name)
+		etag, lastModified = "", ""
+	}
+	err = db.UpdateFeedCacheValidators(storeCtx, database.UpdateFeedCacheValidatorsParams{
+		ID: feed.ID,
+		Etag: sql.NullString{
+			String: etag,
+			Valid:  etag != "",
+		},
+		LastModified: sql.NullString{
+			String: lastModified,
+			Valid:  lastModified != "",
+		},
+	})
+	if err != nil {
+		log.Printf("Couldn't save cache validators for feed %s: %v", feed.Name, err)
+	}
+	// Reschedule now that the new posts are stored, so they count towards the estimate:
+	saveScheduleHints(storeCtx, db, feed, feedData.Schedule)
+	scheduleNextFetch(storeCtx, db, feed, feedData.Schedule, opts)
+	log.Printf("Feed %s collected, %v posts found, %v new, %v updated", feed.Name, len(feedData.Items), newPosts, updatedPosts)
+	return nil
+}
+// saveEnclosures stores the media files attached to a post (podcast episodes and the like),
+// and removes any the feed no longer lists for it. It reports whether all of that worked:
+func saveEnclosures(ctx context.Context, db *database.Queries, post database.Post, enclosures []FeedEnclosure) bool {
+	ok := true
+	urls := make([]string, 0, len(enclosures))
+	for _, enclosure := range enclosures {
+		urls = append(urls, enclosure.URL)
+	}
+	err := db.DeleteStaleEnclosures(ctx, database.DeleteStaleEnclosuresParams{
+		PostID: post.ID,
+		Urls:   urls,
+	})
+	if err != nil {
+		log.Printf("Couldn't remove old enclosures of post %s: %v", post.Url, err)
+		ok = false
+	}
+	for _, enclosure := range enclosures {
+		err := db.UpsertEnclosure(ctx, database.UpsertEnclosureParams{
+			ID:        uuid.New(),
+			CreatedAt: time.Now().UTC(),
+			UpdatedAt: time.Now().UTC(),
+			PostID:    post.ID,
+			Url:       enclosure.URL,
+			MimeType: sql.NullString{
+				String: enclosure.MimeType,
+				Valid:  enclosure.MimeType != "",
+			},
+			Length: sql.NullInt64{
+				Int64: enclosure.Length,
+				Valid: enclosure.Length > 0,
+			},
+			DurationSeconds: sql.NullInt32{
+				Int32: int32(enclosure.DurationSeconds),
+				Valid: enclosure.DurationSeconds > 0,
+			},
+		})
+		if err != nil {
+			log.Printf("Couldn't save enclosure %s: %v", enclosure.URL, err)
+			ok = false
+		}
+	}
+	return ok
+}
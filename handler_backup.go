@@ -0,0 +1,542 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"gator/internal/database"
+	"github.com/google/uuid"
+)
+
+// How many rows of the biggest tables (posts, enclosures and revisions) backup reads from the
+// database at a time:
+const backupPageSize = 500
+
+// Write everything people have put into gator (users, feeds, follows, posts with their media
+// and earlier versions, and what they've read or starred) to a portable JSON Lines file, or
+// to stdout without a file name. Unlike pg_dump, the result can be restored into any gator
+// database, including one that's already in use:
+func handlerBackup(s *state, cmd command) error {
+	if len(cmd.Args) > 1 {
+		return fmt.Errorf("usage: %s [file.jsonl]", cmd.Name)
+	}
+	if len(cmd.Args) == 0 {
+		_, err := backupTo(s, os.Stdout)
+		return err
+	}
+
+	file, err := os.Create(cmd.Args[0])
+	if err != nil {
+		return fmt.Errorf("couldn't create backup file: %w", err)
+	}
+	count, err := backupTo(s, file)
+	// A file that was cut short (or never made it to disk) would look like a good backup until
+	// someone tried to restore it, so remove it unless everything was written:
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("couldn't write backup file: %w", closeErr)
+	}
+	if err != nil {
+		os.Remove(cmd.Args[0])
+		return err
+	}
+	fmt.Printf("Backed up %d records to %s\n", count, cmd.Args[0])
+	return nil
+}
+
+// backupTo writes a backup to out, and returns how many records it wrote:
+func backupTo(s *state, out io.Writer) (int, error) {
+	// Read everything from one snapshot of the database, so a backup taken while agg or
+	// addfeed is running never has posts or follows pointing at a feed it doesn't contain:
+	tx, err := s.sqlDB.BeginTx(context.Background(), &sql.TxOptions{
+		Isolation: sql.LevelRepeatableRead,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("couldn't start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	count, err := writeBackup(context.Background(), database.New(tx), out)
+	if err != nil {
+		return count, fmt.Errorf("couldn't write backup: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return count, fmt.Errorf("couldn't finish transaction: %w", err)
+	}
+	return count, nil
+}
+
+// writeBackup writes the header and every record, parents first, and returns how many
+// records it wrote. json.Encoder puts each value on its own line:
+func writeBackup(ctx context.Context, db *database.Queries, out io.Writer) (int, error) {
+	encoder := json.NewEncoder(out)
+	err := encoder.Encode(backupHeader{
+		Format:    backupFormat,
+		Version:   backupVersion,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	write := func(record backupRecord) error {
+		count++
+		return encoder.Encode(record)
+	}
+
+	users, err := db.GetUsers(ctx)
+	if err != nil {
+		return count, fmt.Errorf("couldn't get users: %w", err)
+	}
+	for _, user := range users {
+		if err := write(backupRecord{Type: "user", User: newBackupUser(user)}); err != nil {
+			return count, err
+		}
+	}
+
+	feeds, err := db.GetFeeds(ctx)
+	if err != nil {
+		return count, fmt.Errorf("couldn't get feeds: %w", err)
+	}
+	for _, feed := range feeds {
+		if err := write(backupRecord{Type: "feed", Feed: newBackupFeed(feed)}); err != nil {
+			return count, err
+		}
+	}
+
+	previousURLs, err := db.GetAllFeedPreviousURLs(ctx)
+	if err != nil {
+		return count, fmt.Errorf("couldn't get previous feed URLs: %w", err)
+	}
+	for _, previous := range previousURLs {
+		if err := write(backupRecord{Type: "feed_previous_url", FeedPreviousURL: newBackupFeedPreviousURL(previous)}); err != nil {
+			return count, err
+		}
+	}
+
+	prunedPosts, err := db.GetAllPrunedPosts(ctx)
+	if err != nil {
+		return count, fmt.Errorf("couldn't get pruned posts: %w", err)
+	}
+	for _, pruned := range prunedPosts {
+		if err := write(backupRecord{Type: "pruned_post", PrunedPost: newBackupPrunedPost(pruned)}); err != nil {
+			return count, err
+		}
+	}
+
+	follows, err := db.GetAllFeedFollows(ctx)
+	if err != nil {
+		return count, fmt.Errorf("couldn't get feed follows: %w", err)
+	}
+	for _, follow := range follows {
+		if err := write(backupRecord{Type: "feed_follow", FeedFollow: newBackupFeedFollow(follow)}); err != nil {
+			return count, err
+		}
+	}
+
+	err = forEachPage(
+		func(afterID uuid.UUID) ([]database.Post, error) {
+			posts, err := db.GetPostsPage(ctx, database.GetPostsPageParams{AfterID: afterID, PageSize: backupPageSize})
+			if err != nil {
+				return nil, fmt.Errorf("couldn't get posts: %w", err)
+			}
+			return posts, nil
+		},
+		func(post database.Post) uuid.UUID { return post.ID },
+		func(post database.Post) error {
+			return write(backupRecord{Type: "post", Post: newBackupPost(post)})
+		},
+	)
+	if err != nil {
+		return count, err
+	}
+
+	err = forEachPage(
+		func(afterID uuid.UUID) ([]database.Enclosure, error) {
+			enclosures, err := db.GetEnclosuresPage(ctx, database.GetEnclosuresPageParams{AfterID: afterID, PageSize: backupPageSize})
+			if err != nil {
+				return nil, fmt.Errorf("couldn't get enclosures: %w", err)
+			}
+			return enclosures, nil
+		},
+		func(enclosure database.Enclosure) uuid.UUID { return enclosure.ID },
+		func(enclosure database.Enclosure) error {
+			return write(backupRecord{Type: "enclosure", Enclosure: newBackupEnclosure(enclosure)})
+		},
+	)
+	if err != nil {
+		return count, err
+	}
+
+	err = forEachPage(
+		func(afterID uuid.UUID) ([]database.PostRevision, error) {
+			revisions, err := db.GetPostRevisionsPage(ctx, database.GetPostRevisionsPageParams{AfterID: afterID, PageSize: backupPageSize})
+			if err != nil {
+				return nil, fmt.Errorf("couldn't get post revisions: %w", err)
+			}
+			return revisions, nil
+		},
+		func(revision database.PostRevision) uuid.UUID { return revision.ID },
+		func(revision database.PostRevision) error {
+			return write(backupRecord{Type: "post_revision", PostRevision: newBackupPostRevision(revision)})
+		},
+	)
+	if err != nil {
+		return count, err
+	}
+
+	reads, err := db.GetAllPostReads(ctx)
+	if err != nil {
+		return count, fmt.Errorf("couldn't get post reads: %w", err)
+	}
+	for _, read := range reads {
+		if err := write(backupRecord{Type: "post_read", PostRead: newBackupPostRead(read)}); err != nil {
+			return count, err
+		}
+	}
+
+	stars, err := db.GetAllPostStars(ctx)
+	if err != nil {
+		return count, fmt.Errorf("couldn't get post stars: %w", err)
+	}
+	for _, star := range stars {
+		if err := write(backupRecord{Type: "post_star", PostStar: newBackupPostStar(star)}); err != nil {
+			return count, err
+		}
+	}
+
+	return count, nil
+}
+
+// forEachPage reads a table a page at a time, in order of ID, and calls fn for every row.
+// getPage returns the rows after the given ID (the zero UUID for the first page):
+func forEachPage[T any](getPage func(afterID uuid.UUID) ([]T, error), id func(T) uuid.UUID, fn func(T) error) error {
+	afterID := uuid.Nil
+	for {
+		rows, err := getPage(afterID)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+		if len(rows) < backupPageSize {
+			return nil
+		}
+		afterID = id(rows[len(rows)-1])
+	}
+}
+
+// Load a file written by backup ("-" reads it from stdin). Restoring is idempotent: rows the
+// database already has are left alone, so it's safe to restore into a database that's in
+// use, or to run the same restore again after it was interrupted:
+func handlerRestore(s *state, cmd command) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("usage: %s <file.jsonl|->", cmd.Name)
+	}
+
+	in := io.Reader(os.Stdin)
+	if cmd.Args[0] != "-" {
+		file, err := os.Open(cmd.Args[0])
+		if err != nil {
+			return fmt.Errorf("couldn't open backup file: %w", err)
+		}
+		defer file.Close()
+		in = file
+	}
+
+	decoder := json.NewDecoder(in)
+	var header backupHeader
+	if err := decoder.Decode(&header); err != nil {
+		return fmt.Errorf("couldn't read backup header: %w", err)
+	}
+	if header.Format != backupFormat {
+		return fmt.Errorf("not a gator backup")
+	}
+	if header.Version < 1 || header.Version > backupVersion {
+		return fmt.Errorf("unsupported backup version %d (this gator understands up to %d)", header.Version, backupVersion)
+	}
+
+	r := newRestorer(s.db)
+	for line := 2; ; line++ {
+		var record backupRecord
+		err := decoder.Decode(&record)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("couldn't read backup record on line %d: %w", line, err)
+		}
+		if err := r.restore(context.Background(), record); err != nil {
+			return fmt.Errorf("couldn't restore %s on line %d: %w", record.Type, line, err)
+		}
+	}
+
+	fmt.Printf("Restored backup from %v:\n", header.CreatedAt)
+	for _, recordType := range r.types {
+		counts := r.counts[recordType]
+		fmt.Printf("* %-18s %d restored, %d already present\n", recordType+":", counts.restored, counts.existing)
+	}
+	return nil
+}
+
+// restorer keeps track of what's been restored so far. The maps translate IDs from the backup
+// into IDs in this database, which differ when a row with the same name, URL or GUID was
+// already here under another ID:
+type restorer struct {
+	db      *database.Queries
+	userIDs map[uuid.UUID]uuid.UUID
+	feedIDs map[uuid.UUID]uuid.UUID
+	postIDs map[uuid.UUID]uuid.UUID
+	// types lists the record types in the order they were first seen, for the summary:
+	types  []string
+	counts map[string]*restoreCounts
+}
+
+type restoreCounts struct {
+	restored int
+	existing int
+}
+
+func newRestorer(db *database.Queries) *restorer {
+	return &restorer{
+		db:      db,
+		userIDs: map[uuid.UUID]uuid.UUID{},
+		feedIDs: map[uuid.UUID]uuid.UUID{},
+		postIDs: map[uuid.UUID]uuid.UUID{},
+		counts:  map[string]*restoreCounts{},
+	}
+}
+
+// count tallies one record of the given type:
+func (r *restorer) count(recordType string, restored bool) {
+	counts, ok := r.counts[recordType]
+	if !ok {
+		counts = &restoreCounts{}
+		r.counts[recordType] = counts
+		r.types = append(r.types, recordType)
+	}
+	if restored {
+		counts.restored++
+	} else {
+		counts.existing++
+	}
+}
+
+// mapID translates a reference from the backup. A row that wasn't in the backup keeps its
+// ID, in case it's already in the database:
+func mapID(ids map[uuid.UUID]uuid.UUID, id uuid.UUID) uuid.UUID {
+	if mapped, ok := ids[id]; ok {
+		return mapped
+	}
+	return id
+}
+
+func (r *restorer) restore(ctx context.Context, record backupRecord) error {
+	switch {
+	case record.Type == "user" && record.User != nil:
+		user := record.User
+		row, err := r.db.RestoreUser(ctx, database.RestoreUserParams{
+			ID:        user.ID,
+			CreatedAt: user.CreatedAt,
+			UpdatedAt: user.UpdatedAt,
+			Name:      user.Name,
+		})
+		// Nothing comes back when the ID is taken by a row with another natural key, which
+		// the backup's references can't be pointed at:
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("ID %s is already used by a different user", user.ID)
+		}
+		if err != nil {
+			return err
+		}
+		r.userIDs[user.ID] = row.ID
+		r.count(record.Type, row.Restored)
+
+	case record.Type == "feed" && record.Feed != nil:
+		feed := record.Feed
+		interval := sql.NullInt32{}
+		if feed.FetchIntervalSeconds != nil {
+			interval = sql.NullInt32{
+				Int32: *feed.FetchIntervalSeconds,
+				Valid: true,
+			}
+		}
+		row, err := r.db.RestoreFeed(ctx, database.RestoreFeedParams{
+			ID:                   feed.ID,
+			CreatedAt:            feed.CreatedAt,
+			UpdatedAt:            feed.UpdatedAt,
+			Name:                 feed.Name,
+			Url:                  feed.URL,
+			UserID:               mapID(r.userIDs, feed.UserID),
+			Description:          nullString(feed.Description),
+			SiteUrl:              nullString(feed.SiteURL),
+			FetchIntervalSeconds: interval,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("ID %s is already used by a different feed", feed.ID)
+		}
+		if err != nil {
+			return err
+		}
+		r.feedIDs[feed.ID] = row.ID
+		r.count(record.Type, row.Restored)
+
+	case record.Type == "feed_previous_url" && record.FeedPreviousURL != nil:
+		previous := record.FeedPreviousURL
+		inserted, err := r.db.RestoreFeedPreviousURL(ctx, database.RestoreFeedPreviousURLParams{
+			Url:       previous.URL,
+			CreatedAt: previous.CreatedAt,
+			FeedID:    mapID(r.feedIDs, previous.FeedID),
+		})
+		if err != nil {
+			return err
+		}
+		r.count(record.Type, inserted > 0)
+
+	case record.Type == "pruned_post" && record.PrunedPost != nil:
+		pruned := record.PrunedPost
+		inserted, err := r.db.RestorePrunedPost(ctx, database.RestorePrunedPostParams{
+			FeedID:   mapID(r.feedIDs, pruned.FeedID),
+			Guid:     pruned.GUID,
+			PrunedAt: pruned.PrunedAt,
+		})
+		if err != nil {
+			return err
+		}
+		r.count(record.Type, inserted > 0)
+
+	case record.Type == "feed_follow" && record.FeedFollow != nil:
+		follow := record.FeedFollow
+		inserted, err := r.db.RestoreFeedFollow(ctx, database.RestoreFeedFollowParams{
+			ID:        follow.ID,
+			CreatedAt: follow.CreatedAt,
+			UpdatedAt: follow.UpdatedAt,
+			UserID:    mapID(r.userIDs, follow.UserID),
+			FeedID:    mapID(r.feedIDs, follow.FeedID),
+			Folder:    nullString(follow.Folder),
+		})
+		if err != nil {
+			return err
+		}
+		r.count(record.Type, inserted > 0)
+
+	case record.Type == "post" && record.Post != nil:
+		post := record.Post
+		publishedAt := sql.NullTime{}
+		if post.PublishedAt != nil {
+			publishedAt = sql.NullTime{
+				Time:  *post.PublishedAt,
+				Valid: true,
+			}
+		}
+		row, err := r.db.RestorePost(ctx, database.RestorePostParams{
+			ID:          post.ID,
+			CreatedAt:   post.CreatedAt,
+			UpdatedAt:   post.UpdatedAt,
+			FeedID:      mapID(r.feedIDs, post.FeedID),
+			Guid:        post.GUID,
+			Title:       post.Title,
+			Url:         post.URL,
+			Description: nullString(post.Description),
+			Content:     nullString(post.Content),
+			PublishedAt: publishedAt,
+			ContentHash: nullString(post.ContentHash),
+
+			PublishedAtInferred: post.PublishedAtInferred,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("ID %s is already used by a different post", post.ID)
+		}
+		if err != nil {
+			return err
+		}
+		r.postIDs[post.ID] = row.ID
+		r.count(record.Type, row.Restored)
+
+	case record.Type == "enclosure" && record.Enclosure != nil:
+		enclosure := record.Enclosure
+		length := sql.NullInt64{}
+		if enclosure.Length != nil {
+			length = sql.NullInt64{
+				Int64: *enclosure.Length,
+				Valid: true,
+			}
+		}
+		duration := sql.NullInt32{}
+		if enclosure.DurationSeconds != nil {
+			duration = sql.NullInt32{
+				Int32: *enclosure.DurationSeconds,
+				Valid: true,
+			}
+		}
+		inserted, err := r.db.RestoreEnclosure(ctx, database.RestoreEnclosureParams{
+			ID:              enclosure.ID,
+			CreatedAt:       enclosure.CreatedAt,
+			UpdatedAt:       enclosure.UpdatedAt,
+			PostID:          mapID(r.postIDs, enclosure.PostID),
+			Url:             enclosure.URL,
+			MimeType:        nullString(enclosure.MimeType),
+			Length:          length,
+			DurationSeconds: duration,
+		})
+		if err != nil {
+			return err
+		}
+		r.count(record.Type, inserted > 0)
+
+	case record.Type == "post_revision" && record.PostRevision != nil:
+		revision := record.PostRevision
+		inserted, err := r.db.RestorePostRevision(ctx, database.RestorePostRevisionParams{
+			ID:          revision.ID,
+			CreatedAt:   revision.CreatedAt,
+			PostID:      mapID(r.postIDs, revision.PostID),
+			Title:       revision.Title,
+			Url:         revision.URL,
+			Description: nullString(revision.Description),
+			Content:     nullString(revision.Content),
+			ContentHash: nullString(revision.ContentHash),
+		})
+		if err != nil {
+			return err
+		}
+		r.count(record.Type, inserted > 0)
+
+	case record.Type == "post_read" && record.PostRead != nil:
+		read := record.PostRead
+		inserted, err := r.db.RestorePostRead(ctx, database.RestorePostReadParams{
+			UserID: mapID(r.userIDs, read.UserID),
+			PostID: mapID(r.postIDs, read.PostID),
+			ReadAt: read.ReadAt,
+		})
+		if err != nil {
+			return err
+		}
+		r.count(record.Type, inserted > 0)
+
+	case record.Type == "post_star" && record.PostStar != nil:
+		star := record.PostStar
+		inserted, err := r.db.RestorePostStar(ctx, database.RestorePostStarParams{
+			UserID:    mapID(r.userIDs, star.UserID),
+			PostID:    mapID(r.postIDs, star.PostID),
+			StarredAt: star.StarredAt,
+		})
+		if err != nil {
+			return err
+		}
+		r.count(record.Type, inserted > 0)
+
+	default:
+		return fmt.Errorf("unknown record type %q", record.Type)
+	}
+	return nil
+}
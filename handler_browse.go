@@ -2,17 +2,30 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
 	"gator/internal/database"
 )
 // Add the browse command. It should take an optional "limit" parameter. 
 // If it's not provided, default the limit to 2:
+// Pass --full to print the whole article body instead of just the description:
+// Every post browse shows is marked read; pass --unread to only show posts that aren't yet:
 func handlerBrowse(s *state, cmd command, user database.User) error {
+	fs := flag.NewFlagSet(cmd.Name, flag.ContinueOnError)
+	full := fs.Bool("full", false, "show the full body of each post")
+	unread := fs.Bool("unread", false, "only show posts you haven't read yet")
+	args, err := parseFlags(fs, cmd.Args)
+	if err != nil || len(args) > 1 {
+		return fmt.Errorf("usage: %s [limit] [--full] [--unread]", cmd.Name)
+	}
+
 	limit := 2
-	if len(cmd.Args) == 1 {
-		if specifiedLimit, err := strconv.Atoi(cmd.Args[0]); err == nil {
+	if len(args) == 1 {
+		if specifiedLimit, err := strconv.Atoi(args[0]); err == nil {
 			limit = specifiedLimit
 		} else {
 			return fmt.Errorf("invalid limit: %w", err)
@@ -20,8 +33,9 @@ func handlerBrowse(s *state, cmd command, user database.User) error {
 	}
 
 	posts, err := s.db.GetPostsForUser(context.Background(), database.GetPostsForUserParams{
-		UserID: user.ID,
-		Limit:  int32(limit),
+		UserID:     user.ID,
+		Limit:      int32(limit),
+		UnreadOnly: *unread,
 	})
 	if err != nil {
 		return fmt.Errorf("couldn't get posts for user: %w", err)
@@ -31,10 +45,80 @@ func handlerBrowse(s *state, cmd command, user database.User) error {
 	for _, post := range posts {
 		fmt.Printf("%s from %s\n", post.PublishedAt.Time.Format("Mon Jan 2"), post.FeedName)
 		fmt.Printf("--- %s ---\n", post.Title)
-		fmt.Printf("    %v\n", post.Description.String)
+		// Fall back to the description for feeds that don't ship a separate body:
+		if *full && post.Content.Valid {
+			fmt.Printf("    %v\n", post.Content.String)
+		} else {
+			fmt.Printf("    %v\n", post.Description.String)
+		}
 		fmt.Printf("Link: %s\n", post.Url)
+		// Show any media files (podcast episodes etc.) attached to the post:
+		enclosures, err := s.db.GetEnclosuresForPost(context.Background(), post.ID)
+		if err != nil {
+			return fmt.Errorf("couldn't get enclosures: %w", err)
+		}
+		for _, enclosure := range enclosures {
+			printEnclosure(enclosure)
+		}
+		fmt.Printf("ID:   %s\n", post.ID)
 		fmt.Println("=====================================")
+
+		_, err = s.db.MarkPostRead(context.Background(), database.MarkPostReadParams{
+			UserID: user.ID,
+			PostID: post.ID,
+			ReadAt: time.Now().UTC(),
+		})
+		if err != nil {
+			return fmt.Errorf("couldn't mark post read: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Mark a post read without browsing to it, e.g. one you've already read somewhere else:
+func handlerRead(s *state, cmd command, user database.User) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("usage: %s <post_id>", cmd.Name)
 	}
 
+	post, err := getPostArg(s, cmd.Args[0])
+	if err != nil {
+		return err
+	}
+
+	marked, err := s.db.MarkPostRead(context.Background(), database.MarkPostReadParams{
+		UserID: user.ID,
+		PostID: post.ID,
+		ReadAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't mark post read: %w", err)
+	}
+	if marked == 0 {
+		fmt.Printf("You've already read %s\n", post.Title)
+		return nil
+	}
+	fmt.Printf("Marked %s as read\n", post.Title)
 	return nil
 }
+
+// printEnclosure prints a media file along with whatever we know about it, e.g.
+// "Enclosure: https://example.com/ep1.mp3 (audio/mpeg, 45.2 MB, 1h2m3s)"
+func printEnclosure(enclosure database.Enclosure) {
+	var details []string
+	if enclosure.MimeType.Valid {
+		details = append(details, enclosure.MimeType.String)
+	}
+	if enclosure.Length.Valid {
+		details = append(details, fmt.Sprintf("%.1f MB", float64(enclosure.Length.Int64)/1e6))
+	}
+	if enclosure.DurationSeconds.Valid {
+		details = append(details, (time.Duration(enclosure.DurationSeconds.Int32) * time.Second).String())
+	}
+	if len(details) == 0 {
+		fmt.Printf("Enclosure: %s\n", enclosure.Url)
+		return
+	}
+	fmt.Printf("Enclosure: %s (%s)\n", enclosure.Url, strings.Join(details, ", "))
+}
@@ -1,22 +1,79 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"gator/internal/database"
 	"github.com/google/uuid"
 )
 
+// The name is optional: without one, the feed is named after its own title.
 func handlerAddFeed(s *state, cmd command, user database.User) error {
 
-	if len(cmd.Args) != 2 {
-		return fmt.Errorf("usage: %s <name> <url>", cmd.Name)
+	if len(cmd.Args) != 1 && len(cmd.Args) != 2 {
+		return fmt.Errorf("usage: %s [name] <url>", cmd.Name)
+	}
+
+	name := ""
+	if len(cmd.Args) == 2 {
+		name = cmd.Args[0]
+	}
+	pageURL := cmd.Args[len(cmd.Args)-1]
+	// Someone may have added the feed already, possibly under the URL it had before it moved:
+	existing, found, err := existingFeed(s, pageURL)
+	if err != nil {
+		return err
+	}
+	if found {
+		return followExistingFeed(s, user, existing)
+	}
+	// The URL is often a blog's homepage rather than its feed, so find the feed(s) behind it
+	// first, and ask which one to add if there's more than one:
+	candidates, err := discoverFeeds(context.Background(), pageURL, s.cfg.FeedSizeLimit())
+	if err != nil {
+		return fmt.Errorf("couldn't find a feed at %s: %w", pageURL, err)
+	}
+	candidate, err := chooseFeedCandidate(pageURL, candidates, os.Stdin)
+	if err != nil {
+		return err
+	}
+	url := candidate.URL
+	existing, found, err = existingFeed(s, url)
+	if err != nil {
+		return err
+	}
+	if found {
+		return followExistingFeed(s, user, existing)
 	}
 
-	name := cmd.Args[0]
-	url := cmd.Args[1]
+	// Make sure the feed actually works before adding it, so nobody follows a feed that agg
+	// will never manage to collect. Discovery may have downloaded it already:
+	feedData := candidate.Feed
+	if feedData == nil {
+		result, err := fetchFeed(context.Background(), fetchRequest{
+			URL:      url,
+			MaxBytes: s.cfg.FeedSizeLimit(),
+		})
+		if err != nil {
+			return fmt.Errorf("%s isn't a valid feed: %w", url, err)
+		}
+		feedData = result.Feed
+	}
+	if name == "" {
+		name = strings.TrimSpace(feedData.Title)
+	}
+	if name == "" {
+		return fmt.Errorf("%s doesn't have a title; usage: %s <name> <url>", url, cmd.Name)
+	}
 
 	// call the CreateFeed method on your database connection with two arguments:
 		// context.Background(): provides a basic context for the database operation
@@ -28,6 +85,14 @@ func handlerAddFeed(s *state, cmd command, user database.User) error {
 		UserID:    user.ID,
 		Name:      name,
 		Url:       url,
+		Description: sql.NullString{
+			String: feedData.Description,
+			Valid:  feedData.Description != "",
+		},
+		SiteUrl: sql.NullString{
+			String: feedData.Link,
+			Valid:  feedData.Link != "",
+		},
 	})
 	if err != nil {
 		return fmt.Errorf("couldn't create feed: %w", err)
@@ -58,6 +123,65 @@ func handlerAddFeed(s *state, cmd command, user database.User) error {
 
 	return nil
 }
+
+// existingFeed looks up a feed that's already been added at a URL, including one it has moved
+// away from (see MoveFeedURL), and reports whether there is one:
+func existingFeed(s *state, url string) (database.Feed, bool, error) {
+	feed, err := s.db.GetFeedByURL(context.Background(), url)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Feed{}, false, nil
+	}
+	if err != nil {
+		return database.Feed{}, false, fmt.Errorf("couldn't look up feed: %w", err)
+	}
+	return feed, true, nil
+}
+
+// followExistingFeed follows a feed someone has already added, rather than adding it again:
+func followExistingFeed(s *state, user database.User, feed database.Feed) error {
+	feedFollow, err := s.db.CreateFeedFollow(context.Background(), database.CreateFeedFollowParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+		UserID:    user.ID,
+		FeedID:    feed.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("%s has already been added, and couldn't follow it: %w", feed.Url, err)
+	}
+	fmt.Printf("%s has already been added, so you're following it instead:\n", feed.Url)
+	printFeedFollow(feedFollow.UserName, feedFollow.FeedName)
+	return nil
+}
+
+// chooseFeedCandidate picks the feed to add out of those found on a website. With just one,
+// there's nothing to choose; otherwise we list them and ask, reading the answer from input:
+func chooseFeedCandidate(pageURL string, candidates []feedCandidate, input io.Reader) (feedCandidate, error) {
+	if len(candidates) == 1 {
+		return candidates[0], nil
+	}
+
+	fmt.Printf("Found %d feeds on %s:\n", len(candidates), pageURL)
+	for i, candidate := range candidates {
+		title := candidate.Title
+		if title == "" {
+			title = "(untitled)"
+		}
+		fmt.Printf("  %d) %s - %s\n", i+1, title, candidate.URL)
+	}
+	fmt.Printf("Which one do you want to add? [1-%d]: ", len(candidates))
+
+	answer, err := bufio.NewReader(input).ReadString('\n')
+	if err != nil && answer == "" {
+		return feedCandidate{}, fmt.Errorf("no feed chosen; run addfeed again with one of the URLs above")
+	}
+	choice, err := strconv.Atoi(strings.TrimSpace(answer))
+	if err != nil || choice < 1 || choice > len(candidates) {
+		return feedCandidate{}, fmt.Errorf("invalid choice: %q", strings.TrimSpace(answer))
+	}
+	return candidates[choice-1], nil
+}
+
 // call the GetFeeds method on your database connection:
 // It returns two values: feeds (a slice of feed records) and err (any error that occurred)
 func handlerListFeeds(s *state, cmd command) error {
@@ -85,6 +209,57 @@ func handlerListFeeds(s *state, cmd command) error {
 	return nil
 }
 
+// The longest fetch interval we accept. Intervals are stored in seconds as an INTEGER, and
+// anything much longer than this is better done by unfollowing the feed:
+const maxFetchInterval = 30 * 24 * time.Hour
+
+// Set how often agg fetches a feed, e.g. "setinterval <url> 10m" for a busy news feed or
+// "setinterval <url> 24h" for a monthly blog. "default" goes back to agg's --default-interval:
+func handlerSetFeedInterval(s *state, cmd command) error {
+	if len(cmd.Args) != 2 {
+		return fmt.Errorf("usage: %s <feed_url> <interval|default>", cmd.Name)
+	}
+
+	feed, err := s.db.GetFeedByURL(context.Background(), cmd.Args[0])
+	if err != nil {
+		return fmt.Errorf("couldn't get feed: %w", err)
+	}
+
+	interval := sql.NullInt32{}
+	if cmd.Args[1] != "default" {
+		duration, err := time.ParseDuration(cmd.Args[1])
+		if err != nil {
+			return fmt.Errorf("invalid interval: %w", err)
+		}
+		if duration < time.Minute || duration > maxFetchInterval {
+			return fmt.Errorf("interval must be between 1m and %s", maxFetchInterval)
+		}
+		interval = sql.NullInt32{
+			Int32: int32(duration.Seconds()),
+			Valid: true,
+		}
+	}
+
+	feed, err = s.db.SetFeedFetchInterval(context.Background(), database.SetFeedFetchIntervalParams{
+		ID:                   feed.ID,
+		FetchIntervalSeconds: interval,
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't set fetch interval: %w", err)
+	}
+
+	fmt.Printf("%s will now be fetched %s\n", feed.Name, describeFetchInterval(feed))
+	return nil
+}
+
+// describeFetchInterval says how often a feed is fetched, e.g. "every 10m0s":
+func describeFetchInterval(feed database.Feed) string {
+	if !feed.FetchIntervalSeconds.Valid {
+		return "at agg's default interval"
+	}
+	return fmt.Sprintf("every %s", time.Duration(feed.FetchIntervalSeconds.Int32)*time.Second)
+}
+
 // If everything goes well, print out the fields of the new feed record:
 func printFeed(feed database.Feed, user database.User) {
 	fmt.Printf("* ID:            %s\n", feed.ID)
@@ -92,6 +267,15 @@ func printFeed(feed database.Feed, user database.User) {
 	fmt.Printf("* Updated:       %v\n", feed.UpdatedAt)
 	fmt.Printf("* Name:          %s\n", feed.Name)
 	fmt.Printf("* URL:           %s\n", feed.Url)
+	if feed.SiteUrl.Valid {
+		fmt.Printf("* Site:          %s\n", feed.SiteUrl.String)
+	}
+	if feed.Description.Valid {
+		fmt.Printf("* Description:   %s\n", feed.Description.String)
+	}
 	fmt.Printf("* User:          %s\n", user.Name)		// pulled from User table, based on users.id
 	fmt.Printf("* LastFetchedAt: %v\n", feed.LastFetchedAt.Time)
+	fmt.Printf("* Fetched:       %s\n", describeFetchInterval(feed))
+	fmt.Printf("* NextFetchAt:   %v\n", feed.NextFetchAt.Time)
+	fmt.Printf("* Health:        %s\n", describeFeedHealth(feed))
 }
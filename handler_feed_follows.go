@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
@@ -15,7 +17,7 @@ func handlerFollow(s *state, cmd command, user database.User) error {
 		return fmt.Errorf("usage: %s <feed_url>", cmd.Name)
 	}
 
-	feed, err := s.db.GetFeedByURL(context.Background(), cmd.Args[0])
+	feed, err := findFeed(s, cmd.Args[0])
 	if err != nil {
 		return fmt.Errorf("couldn't get feed: %w", err)
 	}
@@ -36,6 +38,30 @@ func handlerFollow(s *state, cmd command, user database.User) error {
 	return nil
 }
 
+// findFeed looks up a feed someone wants to follow. If nobody has added that exact URL, it
+// may be the website rather than the feed, so look for the feeds the site advertises and use
+// the first one that's already been added:
+func findFeed(s *state, feedURL string) (database.Feed, error) {
+	feed, err := s.db.GetFeedByURL(context.Background(), feedURL)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return feed, err
+	}
+	candidates, discoverErr := discoverFeeds(context.Background(), feedURL, s.cfg.FeedSizeLimit())
+	// The URL isn't a feed anyone has added, and we couldn't look at what's there either, so
+	// say why, e.g. the site being down:
+	if discoverErr != nil {
+		return database.Feed{}, fmt.Errorf("no feed has been added at %s, and couldn't look for one there: %w", feedURL, discoverErr)
+	}
+	for _, candidate := range candidates {
+		feed, err := s.db.GetFeedByURL(context.Background(), candidate.URL)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		return feed, err
+	}
+	return database.Feed{}, fmt.Errorf("no feed on %s has been added yet; add it with addfeed", feedURL)
+}
+
 func handlerListFeedFollows(s *state, cmd command, user database.User) error {
 
 	feedFollows, err := s.db.GetFeedFollowsForUser(context.Background(), user.ID)
@@ -50,7 +76,11 @@ func handlerListFeedFollows(s *state, cmd command, user database.User) error {
 
 	fmt.Printf("Feed follows for user %s:\n", user.Name)
 	for _, ff := range feedFollows {
-		fmt.Printf("* %s\n", ff.FeedName)
+		if ff.Folder.Valid {
+			fmt.Printf("* %s [%s] (%d unread)\n", ff.FeedName, ff.Folder.String, ff.UnreadCount)
+			continue
+		}
+		fmt.Printf("* %s (%d unread)\n", ff.FeedName, ff.UnreadCount)
 	}
 
 	return nil
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"gator/internal/database"
+)
+
+// List the feeds that agg is having trouble with: ones that failed their last fetch (and are
+// being retried with backoff) and ones it has given up on and disabled:
+func handlerFeedStatus(s *state, cmd command) error {
+	feeds, err := s.db.GetUnhealthyFeeds(context.Background())
+	if err != nil {
+		return fmt.Errorf("couldn't get feeds: %w", err)
+	}
+
+	if len(feeds) == 0 {
+		fmt.Println("All feeds are healthy.")
+		return nil
+	}
+
+	fmt.Printf("Found %d unhealthy feeds:\n", len(feeds))
+	for _, feed := range feeds {
+		fmt.Printf("* Name:        %s\n", feed.Name)
+		fmt.Printf("* URL:         %s\n", feed.Url)
+		fmt.Printf("* Health:      %s\n", describeFeedHealth(feed))
+		if feed.LastHttpStatus.Valid {
+			fmt.Printf("* HTTP status: %d\n", feed.LastHttpStatus.Int32)
+		}
+		fmt.Printf("* Error at:    %v\n", feed.LastErrorAt.Time)
+		fmt.Printf("* Error:       %s\n", feed.LastError.String)
+		fmt.Println("=====================================")
+	}
+
+	return nil
+}
+
+// Put a feed that agg disabled back into rotation, e.g. once its site is back up:
+func handlerEnableFeed(s *state, cmd command) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("usage: %s <feed_url>", cmd.Name)
+	}
+
+	feed, err := s.db.GetFeedByURL(context.Background(), cmd.Args[0])
+	if err != nil {
+		return fmt.Errorf("couldn't get feed: %w", err)
+	}
+
+	feed, err = s.db.EnableFeed(context.Background(), feed.ID)
+	if err != nil {
+		return fmt.Errorf("couldn't enable feed: %w", err)
+	}
+
+	fmt.Printf("%s is enabled and will be fetched on agg's next check\n", feed.Name)
+	return nil
+}
+
+// describeFeedHealth sums up how a feed's recent fetches went, e.g. "3 failures in a row":
+func describeFeedHealth(feed database.Feed) string {
+	switch {
+	case feed.DisabledAt.Valid:
+		return fmt.Sprintf("disabled since %v after %d failures in a row", feed.DisabledAt.Time, feed.ConsecutiveFailures)
+	case feed.ConsecutiveFailures > 0:
+		return fmt.Sprintf("%d failures in a row, retrying at %v", feed.ConsecutiveFailures, feed.NextFetchAt.Time)
+	default:
+		return "ok"
+	}
+}
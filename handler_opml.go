@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"gator/internal/database"
+	"github.com/google/uuid"
+)
+
+// Import the subscriptions exported from another feed reader: every feed in the OPML file is
+// added (unless someone already added it) and followed by the current user, in the folder it
+// was filed under. Feeds aren't downloaded here, so a whole reading list imports quickly;
+// any that turn out to be broken show up in feedstatus once agg has tried them:
+func handlerImport(s *state, cmd command, user database.User) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("usage: %s <file.opml>", cmd.Name)
+	}
+
+	dat, err := os.ReadFile(cmd.Args[0])
+	if err != nil {
+		return fmt.Errorf("couldn't read OPML file: %w", err)
+	}
+	var opml OPML
+	// decodeXML copes with OPML files that aren't in UTF-8, just like it does for feeds:
+	if err := decodeXML(dat, "", &opml); err != nil {
+		return fmt.Errorf("couldn't parse OPML file: %w", err)
+	}
+
+	subscriptions := opml.subscriptions()
+	if len(subscriptions) == 0 {
+		fmt.Println("No feeds found in the OPML file.")
+		return nil
+	}
+
+	created, existing, failed := 0, 0, 0
+	for _, subscription := range subscriptions {
+		feedURL := strings.TrimSpace(subscription.Outline.XMLURL)
+		wasCreated, err := importSubscription(s, user, subscription)
+		switch {
+		case err != nil:
+			failed++
+			fmt.Printf("! Failed:   %s (%s): %v\n", subscription.Outline.name(), feedURL, err)
+		case wasCreated:
+			created++
+			fmt.Printf("+ Created:  %s (%s)\n", subscription.Outline.name(), feedURL)
+		default:
+			existing++
+			fmt.Printf("= Existing: %s (%s)\n", subscription.Outline.name(), feedURL)
+		}
+	}
+
+	fmt.Printf("Imported %d feeds: %d created, %d already existed, %d failed\n", len(subscriptions), created, existing, failed)
+	if failed > 0 {
+		return fmt.Errorf("couldn't import %d feeds", failed)
+	}
+	return nil
+}
+
+// importSubscription adds (if need be) and follows one feed from an OPML file. It reports
+// whether the feed had to be created:
+func importSubscription(s *state, user database.User, subscription opmlSubscription) (bool, error) {
+	outline := subscription.Outline
+	feedURL := strings.TrimSpace(outline.XMLURL)
+	parsed, err := url.Parse(feedURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return false, fmt.Errorf("not a valid feed URL")
+	}
+
+	wasCreated := false
+	feed, err := s.db.GetFeedByURL(context.Background(), feedURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		name := outline.name()
+		if name == "" {
+			name = feedURL
+		}
+		feed, err = s.db.CreateFeed(context.Background(), database.CreateFeedParams{
+			ID:        uuid.New(),
+			CreatedAt: time.Now().UTC(),
+			UpdatedAt: time.Now().UTC(),
+			UserID:    user.ID,
+			Name:      name,
+			Url:       feedURL,
+			Description: sql.NullString{
+				String: outline.Description,
+				Valid:  outline.Description != "",
+			},
+			SiteUrl: sql.NullString{
+				String: outline.HTMLURL,
+				Valid:  outline.HTMLURL != "",
+			},
+		})
+		wasCreated = true
+	}
+	if err != nil {
+		return false, fmt.Errorf("couldn't create feed: %w", err)
+	}
+
+	_, err = s.db.ImportFeedFollow(context.Background(), database.ImportFeedFollowParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+		UserID:    user.ID,
+		FeedID:    feed.ID,
+		Folder: sql.NullString{
+			String: subscription.Folder,
+			Valid:  subscription.Folder != "",
+		},
+	})
+	if err != nil {
+		return wasCreated, fmt.Errorf("couldn't follow feed: %w", err)
+	}
+	return wasCreated, nil
+}
+
+// Export the feeds the current user follows as an OPML 2.0 file that other feed readers can
+// import, with the same folders. Without a file name the OPML goes to stdout, so it can be
+// piped somewhere else:
+func handlerExport(s *state, cmd command, user database.User) error {
+	if len(cmd.Args) > 1 {
+		return fmt.Errorf("usage: %s [file.opml]", cmd.Name)
+	}
+
+	follows, err := s.db.GetFollowedFeedsForUser(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("couldn't get feed follows: %w", err)
+	}
+
+	var subscriptions []opmlSubscription
+	for _, follow := range follows {
+		subscriptions = append(subscriptions, opmlSubscription{
+			Outline: OPMLOutline{
+				Text:        follow.Feed.Name,
+				Title:       follow.Feed.Name,
+				Type:        "rss",
+				XMLURL:      follow.Feed.Url,
+				HTMLURL:     follow.Feed.SiteUrl.String,
+				Description: follow.Feed.Description.String,
+			},
+			Folder: follow.Folder.String,
+		})
+	}
+	opml := newOPML(fmt.Sprintf("%s's feeds in gator", user.Name), user.Name, subscriptions)
+
+	dat, err := xml.MarshalIndent(opml, "", "  ")
+	if err != nil {
+		return fmt.Errorf("couldn't encode OPML: %w", err)
+	}
+	dat = append([]byte(xml.Header), append(dat, '\n')...)
+
+	if len(cmd.Args) == 0 {
+		_, err = os.Stdout.Write(dat)
+		return err
+	}
+	if err := os.WriteFile(cmd.Args[0], dat, 0644); err != nil {
+		return fmt.Errorf("couldn't write OPML file: %w", err)
+	}
+	fmt.Printf("Exported %d feeds to %s\n", len(subscriptions), cmd.Args[0])
+	return nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"gator/internal/database"
+)
+
+// Delete posts older than the given age, e.g. "prune 2160h" keeps the last 90 days. Posts
+// anyone has starred are kept however old they are (see PrunePosts), and agg won't store the
+// deleted ones again if their feed still lists them. Ages under a day are refused, since
+// they'd wipe out posts nobody has had a chance to read yet:
+func handlerPrune(s *state, cmd command) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("usage: %s <age>", cmd.Name)
+	}
+
+	age, err := time.ParseDuration(cmd.Args[0])
+	if err != nil {
+		return fmt.Errorf("invalid age: %w", err)
+	}
+	// The age is passed to the database in seconds, as an INTEGER:
+	if age < 24*time.Hour || age.Seconds() > math.MaxInt32 {
+		return fmt.Errorf("age must be between 24h and %s", time.Duration(math.MaxInt32)*time.Second)
+	}
+
+	deleted, err := s.db.PrunePosts(context.Background(), database.PrunePostsParams{
+		MaxAgeSeconds: int32(age.Seconds()),
+		PrunedAt:      time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't prune posts: %w", err)
+	}
+	fmt.Printf("Deleted %d posts older than %s\n", deleted, age)
+	return nil
+}
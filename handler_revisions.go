@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// Show what an author changed: print the current version of a post followed by every
+// earlier version scrapeFeed kept in post_revisions, newest first:
+func handlerRevisions(s *state, cmd command) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("usage: %s <post_id>", cmd.Name)
+	}
+
+	postID, err := uuid.Parse(cmd.Args[0])
+	if err != nil {
+		return fmt.Errorf("invalid post ID: %w", err)
+	}
+
+	post, err := s.db.GetPostByID(context.Background(), postID)
+	if err != nil {
+		return fmt.Errorf("couldn't get post: %w", err)
+	}
+
+	revisions, err := s.db.GetPostRevisions(context.Background(), postID)
+	if err != nil {
+		return fmt.Errorf("couldn't get post revisions: %w", err)
+	}
+
+	fmt.Printf("Current version (updated %v):\n", post.UpdatedAt)
+	printPostVersion(post.Title, post.Url, post.Description.String)
+	if len(revisions) == 0 {
+		fmt.Println("This post hasn't changed since it was first collected.")
+		return nil
+	}
+
+	fmt.Printf("Found %d earlier versions:\n", len(revisions))
+	for _, revision := range revisions {
+		fmt.Printf("Replaced %v:\n", revision.CreatedAt)
+		printPostVersion(revision.Title, revision.Url, revision.Description.String)
+	}
+	return nil
+}
+
+func printPostVersion(title, url, description string) {
+	fmt.Printf("--- %s ---\n", title)
+	fmt.Printf("    %v\n", description)
+	fmt.Printf("Link: %s\n", url)
+	fmt.Println("=====================================")
+}
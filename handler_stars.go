@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gator/internal/database"
+	"github.com/google/uuid"
+)
+
+// Star a post to keep it: prune skips starred posts, however old they are (see PrunePosts):
+func handlerStar(s *state, cmd command, user database.User) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("usage: %s <post_id>", cmd.Name)
+	}
+
+	post, err := getPostArg(s, cmd.Args[0])
+	if err != nil {
+		return err
+	}
+
+	starred, err := s.db.StarPost(context.Background(), database.StarPostParams{
+		UserID:    user.ID,
+		PostID:    post.ID,
+		StarredAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't star post: %w", err)
+	}
+	if starred == 0 {
+		fmt.Printf("You've already starred %s\n", post.Title)
+		return nil
+	}
+	fmt.Printf("Starred %s\n", post.Title)
+	return nil
+}
+
+// Remove a post from the current user's starred posts:
+func handlerUnstar(s *state, cmd command, user database.User) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("usage: %s <post_id>", cmd.Name)
+	}
+
+	post, err := getPostArg(s, cmd.Args[0])
+	if err != nil {
+		return err
+	}
+
+	unstarred, err := s.db.UnstarPost(context.Background(), database.UnstarPostParams{
+		UserID: user.ID,
+		PostID: post.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't unstar post: %w", err)
+	}
+	if unstarred == 0 {
+		fmt.Printf("You haven't starred %s\n", post.Title)
+		return nil
+	}
+	fmt.Printf("Unstarred %s\n", post.Title)
+	return nil
+}
+
+// List the current user's starred posts, most recently starred first. Like browse, it takes
+// an optional limit, but defaults to 10 since these are posts you chose to keep:
+func handlerStarred(s *state, cmd command, user database.User) error {
+	if len(cmd.Args) > 1 {
+		return fmt.Errorf("usage: %s [limit]", cmd.Name)
+	}
+
+	limit := 10
+	if len(cmd.Args) == 1 {
+		if specifiedLimit, err := strconv.Atoi(cmd.Args[0]); err == nil {
+			limit = specifiedLimit
+		} else {
+			return fmt.Errorf("invalid limit: %w", err)
+		}
+	}
+
+	posts, err := s.db.GetStarredPostsForUser(context.Background(), database.GetStarredPostsForUserParams{
+		UserID: user.ID,
+		Limit:  int32(limit),
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't get starred posts: %w", err)
+	}
+	fmt.Printf("Found %d starred posts for user %s:\n", len(posts), user.Name)
+	for _, post := range posts {
+		fmt.Printf("%s from %s (starred %s)\n", post.PublishedAt.Time.Format("Mon Jan 2"), post.FeedName, post.StarredAt.Format("Mon Jan 2"))
+		fmt.Printf("--- %s ---\n", post.Title)
+		fmt.Printf("    %v\n", post.Description.String)
+		fmt.Printf("Link: %s\n", post.Url)
+		fmt.Printf("ID:   %s\n", post.ID)
+		fmt.Println("=====================================")
+	}
+	return nil
+}
+
+// getPostArg looks up the post a command was given the ID of:
+func getPostArg(s *state, arg string) (database.Post, error) {
+	postID, err := uuid.Parse(arg)
+	if err != nil {
+		return database.Post{}, fmt.Errorf("invalid post ID: %w", err)
+	}
+	post, err := s.db.GetPostByID(context.Background(), postID)
+	if err != nil {
+		return database.Post{}, fmt.Errorf("couldn't get post: %w", err)
+	}
+	return post, nil
+}
@@ -9,10 +9,20 @@ import (
 // You use it anywhere you need the config’s filename (e.g., building ~/<name> paths) so it’s 
 // centralized and not hard-coded in multiple places:
 const configFileName = ".gatorconfig.json"
+// the largest feed we download when max_feed_bytes isn't set in the config file (10 MiB):
+const defaultMaxFeedBytes = 10 << 20
 // define a struct type that mirrors your JSON file’s shape:
 type Config struct {
 	DBURL           string `json:"db_url"`				// DBURL holds the database URL, maps to JSON key db_url
 	CurrentUserName string `json:"current_user_name"`	// holds the logged-in user, maps to JSON key current_user_name
+	MaxFeedBytes    int64  `json:"max_feed_bytes,omitempty"`	// optional cap on the size of a downloaded feed, maps to JSON key max_feed_bytes
+}
+// returns the largest feed (after decompression) we're willing to download:
+func (cfg Config) FeedSizeLimit() int64 {
+	if cfg.MaxFeedBytes > 0 {
+		return cfg.MaxFeedBytes
+	}
+	return defaultMaxFeedBytes
 }
 // method on Config that updates and persists the current user:
 func (cfg *Config) SetUser(userName string) error {
@@ -0,0 +1,586 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: backup.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const getAllFeedFollows = `-- name: GetAllFeedFollows :many
+SELECT id, created_at, updated_at, user_id, feed_id, folder FROM feed_follows ORDER BY created_at, id
+`
+
+// Queries for the backup and restore commands.
+//
+// backup reads everything out in a stable order. Posts can run into the hundreds of
+// thousands, so they're read a page at a time, continuing after the last ID of the previous
+// page (pass the zero UUID to start):
+func (q *Queries) GetAllFeedFollows(ctx context.Context) ([]FeedFollow, error) {
+	rows, err := q.db.QueryContext(ctx, getAllFeedFollows)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FeedFollow
+	for rows.Next() {
+		var i FeedFollow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.FeedID,
+			&i.Folder,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getAllFeedPreviousURLs = `-- name: GetAllFeedPreviousURLs :many
+SELECT url, created_at, feed_id FROM feed_previous_urls ORDER BY created_at, url
+`
+
+func (q *Queries) GetAllFeedPreviousURLs(ctx context.Context) ([]FeedPreviousUrl, error) {
+	rows, err := q.db.QueryContext(ctx, getAllFeedPreviousURLs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FeedPreviousUrl
+	for rows.Next() {
+		var i FeedPreviousUrl
+		if err := rows.Scan(&i.Url, &i.CreatedAt, &i.FeedID); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getAllPrunedPosts = `-- name: GetAllPrunedPosts :many
+SELECT feed_id, guid, pruned_at FROM pruned_posts ORDER BY pruned_at, feed_id, guid
+`
+
+func (q *Queries) GetAllPrunedPosts(ctx context.Context) ([]PrunedPost, error) {
+	rows, err := q.db.QueryContext(ctx, getAllPrunedPosts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PrunedPost
+	for rows.Next() {
+		var i PrunedPost
+		if err := rows.Scan(&i.FeedID, &i.Guid, &i.PrunedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getEnclosuresPage = `-- name: GetEnclosuresPage :many
+SELECT id, created_at, updated_at, post_id, url, mime_type, length, duration_seconds FROM enclosures
+WHERE id > $1
+ORDER BY id
+LIMIT $2
+`
+
+type GetEnclosuresPageParams struct {
+	AfterID  uuid.UUID
+	PageSize int32
+}
+
+// Enclosures and revisions are paged like posts:
+func (q *Queries) GetEnclosuresPage(ctx context.Context, arg GetEnclosuresPageParams) ([]Enclosure, error) {
+	rows, err := q.db.QueryContext(ctx, getEnclosuresPage, arg.AfterID, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Enclosure
+	for rows.Next() {
+		var i Enclosure
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.PostID,
+			&i.Url,
+			&i.MimeType,
+			&i.Length,
+			&i.DurationSeconds,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPostRevisionsPage = `-- name: GetPostRevisionsPage :many
+SELECT id, created_at, post_id, title, url, description, content, content_hash FROM post_revisions
+WHERE id > $1
+ORDER BY id
+LIMIT $2
+`
+
+type GetPostRevisionsPageParams struct {
+	AfterID  uuid.UUID
+	PageSize int32
+}
+
+func (q *Queries) GetPostRevisionsPage(ctx context.Context, arg GetPostRevisionsPageParams) ([]PostRevision, error) {
+	rows, err := q.db.QueryContext(ctx, getPostRevisionsPage, arg.AfterID, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PostRevision
+	for rows.Next() {
+		var i PostRevision
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.PostID,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.Content,
+			&i.ContentHash,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPostsPage = `-- name: GetPostsPage :many
+SELECT id, created_at, updated_at, title, url, description, published_at, feed_id, content, guid, content_hash, published_at_inferred FROM posts
+WHERE id > $1
+ORDER BY id
+LIMIT $2
+`
+
+type GetPostsPageParams struct {
+	AfterID  uuid.UUID
+	PageSize int32
+}
+
+func (q *Queries) GetPostsPage(ctx context.Context, arg GetPostsPageParams) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, getPostsPage, arg.AfterID, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Post
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.Content,
+			&i.Guid,
+			&i.ContentHash,
+			&i.PublishedAtInferred,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const restoreEnclosure = `-- name: RestoreEnclosure :execrows
+INSERT INTO enclosures (id, created_at, updated_at, post_id, url, mime_type, length, duration_seconds)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT DO NOTHING
+`
+
+type RestoreEnclosureParams struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	PostID          uuid.UUID
+	Url             string
+	MimeType        sql.NullString
+	Length          sql.NullInt64
+	DurationSeconds sql.NullInt32
+}
+
+func (q *Queries) RestoreEnclosure(ctx context.Context, arg RestoreEnclosureParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, restoreEnclosure,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.PostID,
+		arg.Url,
+		arg.MimeType,
+		arg.Length,
+		arg.DurationSeconds,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const restoreFeed = `-- name: RestoreFeed :one
+WITH inserted AS (
+    INSERT INTO feeds (id, created_at, updated_at, name, url, user_id, description, site_url, fetch_interval_seconds)
+    VALUES (
+        $1, $2, $3, $4, $5,
+        $6, $7, $8, $9
+    )
+    ON CONFLICT DO NOTHING
+    RETURNING id
+)
+SELECT id, true AS restored FROM inserted
+UNION ALL
+SELECT feeds.id, false AS restored FROM feeds
+WHERE feeds.url = $5
+    -- The feed may have moved since the backup was taken:
+    OR feeds.id IN (SELECT feed_id FROM feed_previous_urls WHERE feed_previous_urls.url = $5)
+ORDER BY restored DESC
+LIMIT 1
+`
+
+type RestoreFeedParams struct {
+	ID                   uuid.UUID
+	CreatedAt            time.Time
+	UpdatedAt            time.Time
+	Name                 string
+	Url                  string
+	UserID               uuid.UUID
+	Description          sql.NullString
+	SiteUrl              sql.NullString
+	FetchIntervalSeconds sql.NullInt32
+}
+
+type RestoreFeedRow struct {
+	ID       uuid.UUID
+	Restored bool
+}
+
+func (q *Queries) RestoreFeed(ctx context.Context, arg RestoreFeedParams) (RestoreFeedRow, error) {
+	row := q.db.QueryRowContext(ctx, restoreFeed,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Name,
+		arg.Url,
+		arg.UserID,
+		arg.Description,
+		arg.SiteUrl,
+		arg.FetchIntervalSeconds,
+	)
+	var i RestoreFeedRow
+	err := row.Scan(&i.ID, &i.Restored)
+	return i, err
+}
+
+const restoreFeedFollow = `-- name: RestoreFeedFollow :execrows
+INSERT INTO feed_follows (id, created_at, updated_at, user_id, feed_id, folder)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT DO NOTHING
+`
+
+type RestoreFeedFollowParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	FeedID    uuid.UUID
+	Folder    sql.NullString
+}
+
+// Follows aren't referred to by anything else, so there's no ID to map:
+func (q *Queries) RestoreFeedFollow(ctx context.Context, arg RestoreFeedFollowParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, restoreFeedFollow,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.UserID,
+		arg.FeedID,
+		arg.Folder,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const restoreFeedPreviousURL = `-- name: RestoreFeedPreviousURL :execrows
+INSERT INTO feed_previous_urls (url, created_at, feed_id)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING
+`
+
+type RestoreFeedPreviousURLParams struct {
+	Url       string
+	CreatedAt time.Time
+	FeedID    uuid.UUID
+}
+
+// Previous URLs, enclosures and revisions aren't referred to by anything either, but point at
+// a feed or a post that restore may have mapped to another ID:
+func (q *Queries) RestoreFeedPreviousURL(ctx context.Context, arg RestoreFeedPreviousURLParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, restoreFeedPreviousURL, arg.Url, arg.CreatedAt, arg.FeedID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const restorePost = `-- name: RestorePost :one
+WITH inserted AS (
+    INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, content, guid, content_hash, published_at_inferred)
+    VALUES (
+        $1, $2, $3, $4, $5,
+        $6, $7, $8, $9,
+        $10, $11, $12
+    )
+    ON CONFLICT DO NOTHING
+    RETURNING id
+)
+SELECT id, true AS restored FROM inserted
+UNION ALL
+SELECT posts.id, false AS restored FROM posts
+WHERE posts.feed_id = $8 AND posts.guid = $10
+ORDER BY restored DESC
+LIMIT 1
+`
+
+type RestorePostParams struct {
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Title               string
+	Url                 string
+	Description         sql.NullString
+	PublishedAt         sql.NullTime
+	FeedID              uuid.UUID
+	Content             sql.NullString
+	Guid                string
+	ContentHash         sql.NullString
+	PublishedAtInferred bool
+}
+
+type RestorePostRow struct {
+	ID       uuid.UUID
+	Restored bool
+}
+
+func (q *Queries) RestorePost(ctx context.Context, arg RestorePostParams) (RestorePostRow, error) {
+	row := q.db.QueryRowContext(ctx, restorePost,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Title,
+		arg.Url,
+		arg.Description,
+		arg.PublishedAt,
+		arg.FeedID,
+		arg.Content,
+		arg.Guid,
+		arg.ContentHash,
+		arg.PublishedAtInferred,
+	)
+	var i RestorePostRow
+	err := row.Scan(&i.ID, &i.Restored)
+	return i, err
+}
+
+const restorePostRead = `-- name: RestorePostRead :execrows
+INSERT INTO post_reads (user_id, post_id, read_at)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING
+`
+
+type RestorePostReadParams struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+	ReadAt time.Time
+}
+
+// Reads point at a user and a post, both of which restore may have mapped to other IDs:
+func (q *Queries) RestorePostRead(ctx context.Context, arg RestorePostReadParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, restorePostRead, arg.UserID, arg.PostID, arg.ReadAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const restorePostRevision = `-- name: RestorePostRevision :execrows
+INSERT INTO post_revisions (id, created_at, post_id, title, url, description, content, content_hash)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT DO NOTHING
+`
+
+type RestorePostRevisionParams struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	PostID      uuid.UUID
+	Title       string
+	Url         string
+	Description sql.NullString
+	Content     sql.NullString
+	ContentHash sql.NullString
+}
+
+func (q *Queries) RestorePostRevision(ctx context.Context, arg RestorePostRevisionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, restorePostRevision,
+		arg.ID,
+		arg.CreatedAt,
+		arg.PostID,
+		arg.Title,
+		arg.Url,
+		arg.Description,
+		arg.Content,
+		arg.ContentHash,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const restorePostStar = `-- name: RestorePostStar :execrows
+INSERT INTO post_stars (user_id, post_id, starred_at)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING
+`
+
+type RestorePostStarParams struct {
+	UserID    uuid.UUID
+	PostID    uuid.UUID
+	StarredAt time.Time
+}
+
+func (q *Queries) RestorePostStar(ctx context.Context, arg RestorePostStarParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, restorePostStar, arg.UserID, arg.PostID, arg.StarredAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const restorePrunedPost = `-- name: RestorePrunedPost :execrows
+INSERT INTO pruned_posts (feed_id, guid, pruned_at)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING
+`
+
+type RestorePrunedPostParams struct {
+	FeedID   uuid.UUID
+	Guid     string
+	PrunedAt time.Time
+}
+
+func (q *Queries) RestorePrunedPost(ctx context.Context, arg RestorePrunedPostParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, restorePrunedPost, arg.FeedID, arg.Guid, arg.PrunedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const restoreUser = `-- name: RestoreUser :one
+WITH inserted AS (
+    INSERT INTO users (id, created_at, updated_at, name)
+    VALUES ($1, $2, $3, $4)
+    ON CONFLICT DO NOTHING
+    RETURNING id
+)
+SELECT id, true AS restored FROM inserted
+UNION ALL
+SELECT users.id, false AS restored FROM users
+WHERE users.name = $4
+ORDER BY restored DESC
+LIMIT 1
+`
+
+type RestoreUserParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Name      string
+}
+
+type RestoreUserRow struct {
+	ID       uuid.UUID
+	Restored bool
+}
+
+// Each restore query inserts the row from the backup unless the database already has it, and
+// returns the ID the row has in this database: the backed-up ID when it was inserted, or the
+// ID of the existing row with the same natural key (a user's name, a feed's URL, a post's
+// feed and GUID), which may differ if the database has been used since. restored says
+// whether the row was inserted. Because rows inserted by a statement aren't visible to the
+// rest of that statement, the new row's ID comes from the inserted CTE instead of the table.
+// When the insert only failed because the ID belongs to some other row, neither half returns
+// anything, and restore reports the clash rather than pointing references at the wrong row.
+func (q *Queries) RestoreUser(ctx context.Context, arg RestoreUserParams) (RestoreUserRow, error) {
+	row := q.db.QueryRowContext(ctx, restoreUser,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Name,
+	)
+	var i RestoreUserRow
+	err := row.Scan(&i.ID, &i.Restored)
+	return i, err
+}
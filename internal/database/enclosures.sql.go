@@ -0,0 +1,106 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: enclosures.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const deleteStaleEnclosures = `-- name: DeleteStaleEnclosures :exec
+DELETE FROM enclosures
+WHERE post_id = $1
+AND NOT (url = ANY($2::TEXT[]))
+`
+
+type DeleteStaleEnclosuresParams struct {
+	PostID uuid.UUID
+	Urls   []string
+}
+
+// Detach the media files a post no longer lists, keeping the ones in urls:
+func (q *Queries) DeleteStaleEnclosures(ctx context.Context, arg DeleteStaleEnclosuresParams) error {
+	_, err := q.db.ExecContext(ctx, deleteStaleEnclosures, arg.PostID, pq.Array(arg.Urls))
+	return err
+}
+
+const getEnclosuresForPost = `-- name: GetEnclosuresForPost :many
+SELECT id, created_at, updated_at, post_id, url, mime_type, length, duration_seconds FROM enclosures
+WHERE post_id = $1
+ORDER BY created_at ASC
+`
+
+func (q *Queries) GetEnclosuresForPost(ctx context.Context, postID uuid.UUID) ([]Enclosure, error) {
+	rows, err := q.db.QueryContext(ctx, getEnclosuresForPost, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Enclosure
+	for rows.Next() {
+		var i Enclosure
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.PostID,
+			&i.Url,
+			&i.MimeType,
+			&i.Length,
+			&i.DurationSeconds,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const upsertEnclosure = `-- name: UpsertEnclosure :exec
+INSERT INTO enclosures (id, created_at, updated_at, post_id, url, mime_type, length, duration_seconds)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT (post_id, url) DO UPDATE
+SET mime_type = EXCLUDED.mime_type,
+    length = EXCLUDED.length,
+    duration_seconds = EXCLUDED.duration_seconds,
+    updated_at = EXCLUDED.updated_at
+`
+
+type UpsertEnclosureParams struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	PostID          uuid.UUID
+	Url             string
+	MimeType        sql.NullString
+	Length          sql.NullInt64
+	DurationSeconds sql.NullInt32
+}
+
+// Attach a media file to a post, or refresh its details if it's already attached:
+func (q *Queries) UpsertEnclosure(ctx context.Context, arg UpsertEnclosureParams) error {
+	_, err := q.db.ExecContext(ctx, upsertEnclosure,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.PostID,
+		arg.Url,
+		arg.MimeType,
+		arg.Length,
+		arg.DurationSeconds,
+	)
+	return err
+}
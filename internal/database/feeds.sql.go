@@ -7,24 +7,133 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const claimFeedsToFetch = `-- name: ClaimFeedsToFetch :many
+WITH due AS (
+    SELECT id, last_fetched_at, next_fetch_at FROM feeds
+    WHERE (next_fetch_at IS NULL OR next_fetch_at <= $2::timestamptz)
+    AND disabled_at IS NULL
+    ORDER BY next_fetch_at ASC NULLS FIRST
+    LIMIT $3
+    FOR UPDATE SKIP LOCKED
+)
+UPDATE feeds
+SET last_fetched_at = NOW(),
+next_fetch_at = NOW() + make_interval(secs => COALESCE(feeds.fetch_interval_seconds, $1::integer)),
+updated_at = NOW()
+FROM due
+WHERE feeds.id = due.id
+RETURNING feeds.id, feeds.created_at, feeds.updated_at, feeds.name, feeds.url, feeds.user_id, feeds.last_fetched_at, feeds.etag, feeds.last_modified, feeds.fetch_interval_seconds, feeds.next_fetch_at, feeds.ttl_minutes, feeds.skip_hours, feeds.skip_days, feeds.last_error, feeds.last_error_at, feeds.consecutive_failures, feeds.last_http_status, feeds.disabled_at, feeds.redirect_url, feeds.redirect_count, feeds.description, feeds.site_url, due.last_fetched_at AS previous_fetched_at, due.next_fetch_at AS previous_next_fetch_at
+`
+
+type ClaimFeedsToFetchParams struct {
+	DefaultIntervalSeconds int32
+	DueBefore              time.Time
+	BatchSize              int32
+}
+
+type ClaimFeedsToFetchRow struct {
+	Feed                Feed
+	PreviousFetchedAt   sql.NullTime
+	PreviousNextFetchAt sql.NullTime
+}
+
+// Add a MarkFeedFetched SQL query. It should simply set the last_fetched_at and updated_at
+// columns to the current time for a given feed (probably by ID is simplest):
+// Add a GetNextFeedToFetch SQL query. It should return the next feed we should fetch posts from.
+// We want to scrape all the feeds in a continuous loop. A simple approach is to keep track of when
+// a feed was last fetched, and always fetch the oldest one first (or any that haven't ever been
+// fetched). SQL has a NULLS FIRST clause that can help with this
+// Several agg processes can share one database, so selecting and marking happen in a single
+// statement: FOR UPDATE SKIP LOCKED makes each process skip rows another one is claiming
+// right now.
+// Feeds are fetched when they're due (next_fetch_at is before due_before), most overdue first.
+// Claiming a feed schedules its next fetch one fetch interval from now. Disabled feeds are
+// never claimed:
+func (q *Queries) ClaimFeedsToFetch(ctx context.Context, arg ClaimFeedsToFetchParams) ([]ClaimFeedsToFetchRow, error) {
+	rows, err := q.db.QueryContext(ctx, claimFeedsToFetch, arg.DefaultIntervalSeconds, arg.DueBefore, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ClaimFeedsToFetchRow
+	for rows.Next() {
+		var i ClaimFeedsToFetchRow
+		if err := rows.Scan(
+			&i.Feed.ID,
+			&i.Feed.CreatedAt,
+			&i.Feed.UpdatedAt,
+			&i.Feed.Name,
+			&i.Feed.Url,
+			&i.Feed.UserID,
+			&i.Feed.LastFetchedAt,
+			&i.Feed.Etag,
+			&i.Feed.LastModified,
+			&i.Feed.FetchIntervalSeconds,
+			&i.Feed.NextFetchAt,
+			&i.Feed.TtlMinutes,
+			pq.Array(&i.Feed.SkipHours),
+			pq.Array(&i.Feed.SkipDays),
+			&i.Feed.LastError,
+			&i.Feed.LastErrorAt,
+			&i.Feed.ConsecutiveFailures,
+			&i.Feed.LastHttpStatus,
+			&i.Feed.DisabledAt,
+			&i.Feed.RedirectUrl,
+			&i.Feed.RedirectCount,
+			&i.Feed.Description,
+			&i.Feed.SiteUrl,
+			&i.PreviousFetchedAt,
+			&i.PreviousNextFetchAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const clearFeedRedirect = `-- name: ClearFeedRedirect :exec
+UPDATE feeds
+SET redirect_url = NULL,
+redirect_count = 0,
+updated_at = NOW()
+WHERE id = $1 AND redirect_url IS NOT NULL
+`
+
+// A fetch that wasn't permanently redirected breaks any run of redirects:
+func (q *Queries) ClearFeedRedirect(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, clearFeedRedirect, id)
+	return err
+}
+
 const createFeed = `-- name: CreateFeed :one
-INSERT INTO feeds (id, created_at, updated_at, name, url, user_id)
-VALUES ($1, $2, $3, $4, $5, $6)
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
+INSERT INTO feeds (id, created_at, updated_at, name, url, user_id, description, site_url)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, fetch_interval_seconds, next_fetch_at, ttl_minutes, skip_hours, skip_days, last_error, last_error_at, consecutive_failures, last_http_status, disabled_at, redirect_url, redirect_count, description, site_url
 `
 
 type CreateFeedParams struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Name      string
-	Url       string
-	UserID    uuid.UUID
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Name        string
+	Url         string
+	UserID      uuid.UUID
+	Description sql.NullString
+	SiteUrl     sql.NullString
 }
 
 func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, error) {
@@ -35,7 +144,51 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		arg.Name,
 		arg.Url,
 		arg.UserID,
+		arg.Description,
+		arg.SiteUrl,
+	)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.FetchIntervalSeconds,
+		&i.NextFetchAt,
+		&i.TtlMinutes,
+		pq.Array(&i.SkipHours),
+		pq.Array(&i.SkipDays),
+		&i.LastError,
+		&i.LastErrorAt,
+		&i.ConsecutiveFailures,
+		&i.LastHttpStatus,
+		&i.DisabledAt,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.Description,
+		&i.SiteUrl,
 	)
+	return i, err
+}
+
+const enableFeed = `-- name: EnableFeed :one
+UPDATE feeds
+SET disabled_at = NULL,
+consecutive_failures = 0,
+next_fetch_at = NULL,
+updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, fetch_interval_seconds, next_fetch_at, ttl_minutes, skip_hours, skip_days, last_error, last_error_at, consecutive_failures, last_http_status, disabled_at, redirect_url, redirect_count, description, site_url
+`
+
+// Put a disabled feed back into rotation with a clean slate. It's due straight away:
+func (q *Queries) EnableFeed(ctx context.Context, id uuid.UUID) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, enableFeed, id)
 	var i Feed
 	err := row.Scan(
 		&i.ID,
@@ -45,15 +198,36 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.FetchIntervalSeconds,
+		&i.NextFetchAt,
+		&i.TtlMinutes,
+		pq.Array(&i.SkipHours),
+		pq.Array(&i.SkipDays),
+		&i.LastError,
+		&i.LastErrorAt,
+		&i.ConsecutiveFailures,
+		&i.LastHttpStatus,
+		&i.DisabledAt,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.Description,
+		&i.SiteUrl,
 	)
 	return i, err
 }
 
 const getFeedByURL = `-- name: GetFeedByURL :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
-WHERE url = $1
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, fetch_interval_seconds, next_fetch_at, ttl_minutes, skip_hours, skip_days, last_error, last_error_at, consecutive_failures, last_http_status, disabled_at, redirect_url, redirect_count, description, site_url FROM feeds
+WHERE feeds.url = $1
+OR feeds.id = (SELECT feed_previous_urls.feed_id FROM feed_previous_urls WHERE feed_previous_urls.url = $1)
+ORDER BY feeds.url = $1 DESC
+LIMIT 1
 `
 
+// A feed that has moved can still be found by any URL it used to have (see MoveFeedURL).
+// If some other feed has since been added at an old URL, that one is the better match:
 func (q *Queries) GetFeedByURL(ctx context.Context, url string) (Feed, error) {
 	row := q.db.QueryRowContext(ctx, getFeedByURL, url)
 	var i Feed
@@ -65,12 +239,28 @@ func (q *Queries) GetFeedByURL(ctx context.Context, url string) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.FetchIntervalSeconds,
+		&i.NextFetchAt,
+		&i.TtlMinutes,
+		pq.Array(&i.SkipHours),
+		pq.Array(&i.SkipDays),
+		&i.LastError,
+		&i.LastErrorAt,
+		&i.ConsecutiveFailures,
+		&i.LastHttpStatus,
+		&i.DisabledAt,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.Description,
+		&i.SiteUrl,
 	)
 	return i, err
 }
 
 const getFeeds = `-- name: GetFeeds :many
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, fetch_interval_seconds, next_fetch_at, ttl_minutes, skip_hours, skip_days, last_error, last_error_at, consecutive_failures, last_http_status, disabled_at, redirect_url, redirect_count, description, site_url FROM feeds
 `
 
 func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
@@ -90,6 +280,22 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 			&i.Url,
 			&i.UserID,
 			&i.LastFetchedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.FetchIntervalSeconds,
+			&i.NextFetchAt,
+			&i.TtlMinutes,
+			pq.Array(&i.SkipHours),
+			pq.Array(&i.SkipDays),
+			&i.LastError,
+			&i.LastErrorAt,
+			&i.ConsecutiveFailures,
+			&i.LastHttpStatus,
+			&i.DisabledAt,
+			&i.RedirectUrl,
+			&i.RedirectCount,
+			&i.Description,
+			&i.SiteUrl,
 		); err != nil {
 			return nil, err
 		}
@@ -104,18 +310,87 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 	return items, nil
 }
 
-const getNextFeedToFetch = `-- name: GetNextFeedToFetch :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
-ORDER BY last_fetched_at ASC NULLS FIRST
-LIMIT 1
+const getUnhealthyFeeds = `-- name: GetUnhealthyFeeds :many
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, fetch_interval_seconds, next_fetch_at, ttl_minutes, skip_hours, skip_days, last_error, last_error_at, consecutive_failures, last_http_status, disabled_at, redirect_url, redirect_count, description, site_url FROM feeds
+WHERE consecutive_failures > 0 OR disabled_at IS NOT NULL
+ORDER BY disabled_at IS NULL, consecutive_failures DESC, name
 `
 
-// Add a GetNextFeedToFetch SQL query. It should return the next feed we should fetch posts from.
-// We want to scrape all the feeds in a continuous loop. A simple approach is to keep track of when
-// a feed was last fetched, and always fetch the oldest one first (or any that haven't ever been
-// fetched). SQL has a NULLS FIRST clause that can help with this
-func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
-	row := q.db.QueryRowContext(ctx, getNextFeedToFetch)
+// Feeds that are failing or have been disabled, worst first:
+func (q *Queries) GetUnhealthyFeeds(ctx context.Context) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getUnhealthyFeeds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.FetchIntervalSeconds,
+			&i.NextFetchAt,
+			&i.TtlMinutes,
+			pq.Array(&i.SkipHours),
+			pq.Array(&i.SkipDays),
+			&i.LastError,
+			&i.LastErrorAt,
+			&i.ConsecutiveFailures,
+			&i.LastHttpStatus,
+			&i.DisabledAt,
+			&i.RedirectUrl,
+			&i.RedirectCount,
+			&i.Description,
+			&i.SiteUrl,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const moveFeedURL = `-- name: MoveFeedURL :one
+WITH forgotten AS (
+    DELETE FROM feed_previous_urls
+    WHERE feed_previous_urls.url = $1
+), remembered AS (
+    INSERT INTO feed_previous_urls (url, created_at, feed_id)
+    SELECT feeds.url, NOW(), feeds.id FROM feeds WHERE id = $2
+    ON CONFLICT (url) DO UPDATE SET created_at = EXCLUDED.created_at, feed_id = EXCLUDED.feed_id
+)
+UPDATE feeds
+SET url = $1,
+redirect_url = NULL,
+redirect_count = 0,
+updated_at = NOW()
+WHERE feeds.id = $2
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, fetch_interval_seconds, next_fetch_at, ttl_minutes, skip_hours, skip_days, last_error, last_error_at, consecutive_failures, last_http_status, disabled_at, redirect_url, redirect_count, description, site_url
+`
+
+type MoveFeedURLParams struct {
+	Url string
+	ID  uuid.UUID
+}
+
+// Move a feed to a new URL, remembering the old one in feed_previous_urls. If the feed is
+// moving back to a URL it used to have, that URL isn't a previous one any more:
+func (q *Queries) MoveFeedURL(ctx context.Context, arg MoveFeedURLParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, moveFeedURL, arg.Url, arg.ID)
 	var i Feed
 	err := row.Scan(
 		&i.ID,
@@ -125,22 +400,205 @@ func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.FetchIntervalSeconds,
+		&i.NextFetchAt,
+		&i.TtlMinutes,
+		pq.Array(&i.SkipHours),
+		pq.Array(&i.SkipDays),
+		&i.LastError,
+		&i.LastErrorAt,
+		&i.ConsecutiveFailures,
+		&i.LastHttpStatus,
+		&i.DisabledAt,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.Description,
+		&i.SiteUrl,
 	)
 	return i, err
 }
 
-const markFeedFetched = `-- name: MarkFeedFetched :one
+const recordFeedFailure = `-- name: RecordFeedFailure :one
 UPDATE feeds
-SET last_fetched_at = NOW(),
+SET consecutive_failures = consecutive_failures + 1,
+last_error = $1,
+last_error_at = NOW(),
+last_http_status = $2,
+next_fetch_at = NOW() + make_interval(secs => LEAST(
+    COALESCE(fetch_interval_seconds, $3::integer) * power(2, consecutive_failures),
+    $4::integer
+)),
+disabled_at = CASE
+    WHEN $5::integer > 0 AND consecutive_failures + 1 >= $5::integer THEN NOW()
+    ELSE disabled_at
+END,
+updated_at = NOW()
+WHERE id = $6
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, fetch_interval_seconds, next_fetch_at, ttl_minutes, skip_hours, skip_days, last_error, last_error_at, consecutive_failures, last_http_status, disabled_at, redirect_url, redirect_count, description, site_url
+`
+
+type RecordFeedFailureParams struct {
+	LastError              sql.NullString
+	LastHttpStatus         sql.NullInt32
+	DefaultIntervalSeconds int32
+	MaxBackoffSeconds      int32
+	MaxFailures            int32
+	ID                     uuid.UUID
+}
+
+// Record a failed fetch. Each failure in a row doubles the wait before the next attempt,
+// starting from the feed's usual interval and capped at max_backoff_seconds, and the feed is
+// disabled once it has failed max_failures times in a row (0 means never disable it).
+// In SET, consecutive_failures still refers to the count before this failure:
+func (q *Queries) RecordFeedFailure(ctx context.Context, arg RecordFeedFailureParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, recordFeedFailure,
+		arg.LastError,
+		arg.LastHttpStatus,
+		arg.DefaultIntervalSeconds,
+		arg.MaxBackoffSeconds,
+		arg.MaxFailures,
+		arg.ID,
+	)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.FetchIntervalSeconds,
+		&i.NextFetchAt,
+		&i.TtlMinutes,
+		pq.Array(&i.SkipHours),
+		pq.Array(&i.SkipDays),
+		&i.LastError,
+		&i.LastErrorAt,
+		&i.ConsecutiveFailures,
+		&i.LastHttpStatus,
+		&i.DisabledAt,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.Description,
+		&i.SiteUrl,
+	)
+	return i, err
+}
+
+const recordFeedRedirect = `-- name: RecordFeedRedirect :one
+UPDATE feeds
+SET redirect_count = CASE WHEN redirect_url = $1::text THEN redirect_count + 1 ELSE 1 END,
+redirect_url = $1::text,
+updated_at = NOW()
+WHERE id = $2
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, fetch_interval_seconds, next_fetch_at, ttl_minutes, skip_hours, skip_days, last_error, last_error_at, consecutive_failures, last_http_status, disabled_at, redirect_url, redirect_count, description, site_url
+`
+
+type RecordFeedRedirectParams struct {
+	RedirectUrl string
+	ID          uuid.UUID
+}
+
+// Note that fetching a feed's URL permanently redirected to redirect_url. redirect_count
+// counts how many fetches in a row have been redirected to that same place:
+func (q *Queries) RecordFeedRedirect(ctx context.Context, arg RecordFeedRedirectParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, recordFeedRedirect, arg.RedirectUrl, arg.ID)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.FetchIntervalSeconds,
+		&i.NextFetchAt,
+		&i.TtlMinutes,
+		pq.Array(&i.SkipHours),
+		pq.Array(&i.SkipDays),
+		&i.LastError,
+		&i.LastErrorAt,
+		&i.ConsecutiveFailures,
+		&i.LastHttpStatus,
+		&i.DisabledAt,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.Description,
+		&i.SiteUrl,
+	)
+	return i, err
+}
+
+const recordFeedSuccess = `-- name: RecordFeedSuccess :exec
+UPDATE feeds
+SET consecutive_failures = 0,
+last_http_status = $2,
 updated_at = NOW()
 WHERE id = $1
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
 `
 
-// Add a MarkFeedFetched SQL query. It should simply set the last_fetched_at and updated_at
-// columns to the current time for a given feed (probably by ID is simplest):
-func (q *Queries) MarkFeedFetched(ctx context.Context, id uuid.UUID) (Feed, error) {
-	row := q.db.QueryRowContext(ctx, markFeedFetched, id)
+type RecordFeedSuccessParams struct {
+	ID             uuid.UUID
+	LastHttpStatus sql.NullInt32
+}
+
+// Record a successful fetch, which ends any run of failures. last_error is kept so
+// feedstatus can still show what went wrong most recently:
+func (q *Queries) RecordFeedSuccess(ctx context.Context, arg RecordFeedSuccessParams) error {
+	_, err := q.db.ExecContext(ctx, recordFeedSuccess, arg.ID, arg.LastHttpStatus)
+	return err
+}
+
+const releaseFeedClaim = `-- name: ReleaseFeedClaim :exec
+UPDATE feeds
+SET last_fetched_at = $2,
+next_fetch_at = $3,
+updated_at = NOW()
+WHERE id = $1
+`
+
+type ReleaseFeedClaimParams struct {
+	ID            uuid.UUID
+	LastFetchedAt sql.NullTime
+	NextFetchAt   sql.NullTime
+}
+
+// Hand a claimed feed back when agg shuts down before fetching it, restoring the
+// last_fetched_at and next_fetch_at it had before ClaimFeedsToFetch so it's still due:
+func (q *Queries) ReleaseFeedClaim(ctx context.Context, arg ReleaseFeedClaimParams) error {
+	_, err := q.db.ExecContext(ctx, releaseFeedClaim, arg.ID, arg.LastFetchedAt, arg.NextFetchAt)
+	return err
+}
+
+const setFeedFetchInterval = `-- name: SetFeedFetchInterval :one
+UPDATE feeds
+SET fetch_interval_seconds = $1,
+next_fetch_at = CASE
+    WHEN $1::integer IS NULL OR next_fetch_at IS NULL THEN next_fetch_at
+    ELSE LEAST(next_fetch_at, COALESCE(last_fetched_at, NOW()) + make_interval(secs => $1::integer))
+END,
+updated_at = NOW()
+WHERE id = $2
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, fetch_interval_seconds, next_fetch_at, ttl_minutes, skip_hours, skip_days, last_error, last_error_at, consecutive_failures, last_http_status, disabled_at, redirect_url, redirect_count, description, site_url
+`
+
+type SetFeedFetchIntervalParams struct {
+	FetchIntervalSeconds sql.NullInt32
+	ID                   uuid.UUID
+}
+
+// Set how often a feed is fetched, or pass NULL to go back to agg's default. A shorter
+// interval takes effect straight away: the next fetch is brought forward if it's now overdue:
+func (q *Queries) SetFeedFetchInterval(ctx context.Context, arg SetFeedFetchIntervalParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, setFeedFetchInterval, arg.FetchIntervalSeconds, arg.ID)
 	var i Feed
 	err := row.Scan(
 		&i.ID,
@@ -150,6 +608,88 @@ func (q *Queries) MarkFeedFetched(ctx context.Context, id uuid.UUID) (Feed, erro
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.FetchIntervalSeconds,
+		&i.NextFetchAt,
+		&i.TtlMinutes,
+		pq.Array(&i.SkipHours),
+		pq.Array(&i.SkipDays),
+		&i.LastError,
+		&i.LastErrorAt,
+		&i.ConsecutiveFailures,
+		&i.LastHttpStatus,
+		&i.DisabledAt,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.Description,
+		&i.SiteUrl,
 	)
 	return i, err
 }
+
+const setFeedNextFetch = `-- name: SetFeedNextFetch :exec
+UPDATE feeds
+SET next_fetch_at = NOW() + make_interval(secs => $1),
+updated_at = NOW()
+WHERE id = $2
+`
+
+type SetFeedNextFetchParams struct {
+	DelaySeconds float64
+	ID           uuid.UUID
+}
+
+// Schedule a feed's next fetch delay_seconds from now. The delay is worked out by the adaptive
+// scheduler in Go, but the timestamp comes from the database clock like everywhere else:
+func (q *Queries) SetFeedNextFetch(ctx context.Context, arg SetFeedNextFetchParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedNextFetch, arg.DelaySeconds, arg.ID)
+	return err
+}
+
+const updateFeedCacheValidators = `-- name: UpdateFeedCacheValidators :exec
+UPDATE feeds
+SET etag = $2,
+last_modified = $3,
+updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateFeedCacheValidatorsParams struct {
+	ID           uuid.UUID
+	Etag         sql.NullString
+	LastModified sql.NullString
+}
+
+// Store the cache validators from a feed's latest 200 response, for the next conditional GET:
+func (q *Queries) UpdateFeedCacheValidators(ctx context.Context, arg UpdateFeedCacheValidatorsParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedCacheValidators, arg.ID, arg.Etag, arg.LastModified)
+	return err
+}
+
+const updateFeedScheduleHints = `-- name: UpdateFeedScheduleHints :exec
+UPDATE feeds
+SET ttl_minutes = $2,
+skip_hours = $3,
+skip_days = $4,
+updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateFeedScheduleHintsParams struct {
+	ID         uuid.UUID
+	TtlMinutes sql.NullInt32
+	SkipHours  []int32
+	SkipDays   []string
+}
+
+// Remember the <ttl>, <skipHours> and <skipDays> hints from a feed's latest 200 response:
+func (q *Queries) UpdateFeedScheduleHints(ctx context.Context, arg UpdateFeedScheduleHintsParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedScheduleHints,
+		arg.ID,
+		arg.TtlMinutes,
+		pq.Array(arg.SkipHours),
+		pq.Array(arg.SkipDays),
+	)
+	return err
+}
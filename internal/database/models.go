@@ -11,14 +11,41 @@ import (
 	"github.com/google/uuid"
 )
 
+type Enclosure struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	PostID          uuid.UUID
+	Url             string
+	MimeType        sql.NullString
+	Length          sql.NullInt64
+	DurationSeconds sql.NullInt32
+}
+
 type Feed struct {
-	ID            uuid.UUID
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	Name          string
-	Url           string
-	UserID        uuid.UUID
-	LastFetchedAt sql.NullTime
+	ID                   uuid.UUID
+	CreatedAt            time.Time
+	UpdatedAt            time.Time
+	Name                 string
+	Url                  string
+	UserID               uuid.UUID
+	LastFetchedAt        sql.NullTime
+	Etag                 sql.NullString
+	LastModified         sql.NullString
+	FetchIntervalSeconds sql.NullInt32
+	NextFetchAt          sql.NullTime
+	TtlMinutes           sql.NullInt32
+	SkipHours            []int32
+	SkipDays             []string
+	LastError            sql.NullString
+	LastErrorAt          sql.NullTime
+	ConsecutiveFailures  int32
+	LastHttpStatus       sql.NullInt32
+	DisabledAt           sql.NullTime
+	RedirectUrl          sql.NullString
+	RedirectCount        int32
+	Description          sql.NullString
+	SiteUrl              sql.NullString
 }
 
 type FeedFollow struct {
@@ -27,17 +54,57 @@ type FeedFollow struct {
 	UpdatedAt time.Time
 	UserID    uuid.UUID
 	FeedID    uuid.UUID
+	Folder    sql.NullString
+}
+
+type FeedPreviousUrl struct {
+	Url       string
+	CreatedAt time.Time
+	FeedID    uuid.UUID
 }
 
 type Post struct {
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Title               string
+	Url                 string
+	Description         sql.NullString
+	PublishedAt         sql.NullTime
+	FeedID              uuid.UUID
+	Content             sql.NullString
+	Guid                string
+	ContentHash         sql.NullString
+	PublishedAtInferred bool
+}
+
+type PostRead struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+	ReadAt time.Time
+}
+
+type PostRevision struct {
 	ID          uuid.UUID
 	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	PostID      uuid.UUID
 	Title       string
 	Url         string
 	Description sql.NullString
-	PublishedAt sql.NullTime
-	FeedID      uuid.UUID
+	Content     sql.NullString
+	ContentHash sql.NullString
+}
+
+type PostStar struct {
+	UserID    uuid.UUID
+	PostID    uuid.UUID
+	StarredAt time.Time
+}
+
+type PrunedPost struct {
+	FeedID   uuid.UUID
+	Guid     string
+	PrunedAt time.Time
 }
 
 type User struct {
@@ -0,0 +1,62 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: post_reads.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const getAllPostReads = `-- name: GetAllPostReads :many
+SELECT user_id, post_id, read_at FROM post_reads ORDER BY read_at, user_id, post_id
+`
+
+func (q *Queries) GetAllPostReads(ctx context.Context) ([]PostRead, error) {
+	rows, err := q.db.QueryContext(ctx, getAllPostReads)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PostRead
+	for rows.Next() {
+		var i PostRead
+		if err := rows.Scan(&i.UserID, &i.PostID, &i.ReadAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markPostRead = `-- name: MarkPostRead :execrows
+INSERT INTO post_reads (user_id, post_id, read_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, post_id) DO NOTHING
+`
+
+type MarkPostReadParams struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+	ReadAt time.Time
+}
+
+// Mark a post read for a user. Reading it again keeps the time it was first read, and
+// affects no rows:
+func (q *Queries) MarkPostRead(ctx context.Context, arg MarkPostReadParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markPostRead, arg.UserID, arg.PostID, arg.ReadAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -13,36 +13,72 @@ import (
 	"github.com/google/uuid"
 )
 
-const createPost = `-- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id
+const adoptLegacyPost = `-- name: AdoptLegacyPost :exec
+UPDATE posts SET guid = $1
+WHERE posts.feed_id = $2
+AND posts.guid = $3
+AND NOT EXISTS (
+    SELECT 1 FROM posts AS adopted
+    WHERE adopted.feed_id = $2 AND adopted.guid = $1
+)
 `
 
-type CreatePostParams struct {
+type AdoptLegacyPostParams struct {
+	Guid       string
+	FeedID     uuid.UUID
+	LegacyGuid string
+}
+
+// Posts stored before we kept GUIDs were given the hash of their link as one (see
+// 007_posts_guid.sql). The first time we see such a post under its real GUID, move the old row
+// over to it, so UpsertPost finds it instead of storing the post a second time. Does nothing
+// once the row has been adopted, or if the post is already stored under its real GUID:
+func (q *Queries) AdoptLegacyPost(ctx context.Context, arg AdoptLegacyPostParams) error {
+	_, err := q.db.ExecContext(ctx, adoptLegacyPost, arg.Guid, arg.FeedID, arg.LegacyGuid)
+	return err
+}
+
+const createPostRevision = `-- name: CreatePostRevision :exec
+INSERT INTO post_revisions (id, created_at, post_id, title, url, description, content, content_hash)
+SELECT $1, $2, posts.id, posts.title, posts.url, posts.description, posts.content, posts.content_hash
+FROM posts
+WHERE posts.feed_id = $3
+AND posts.guid = $4
+AND posts.content_hash IS DISTINCT FROM $5
+`
+
+type CreatePostRevisionParams struct {
 	ID          uuid.UUID
 	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Title       string
-	Url         string
-	Description sql.NullString
-	PublishedAt sql.NullTime
 	FeedID      uuid.UUID
+	Guid        string
+	ContentHash sql.NullString
 }
 
-// Add a "create post" SQL query to the database. This should insert
-// a new post into the database:
-func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
-	row := q.db.QueryRowContext(ctx, createPost,
+// Copy the stored version of a post into post_revisions before UpsertPost overwrites it.
+// Inserts nothing if the post is new or its content hash hasn't changed:
+func (q *Queries) CreatePostRevision(ctx context.Context, arg CreatePostRevisionParams) error {
+	_, err := q.db.ExecContext(ctx, createPostRevision,
 		arg.ID,
 		arg.CreatedAt,
-		arg.UpdatedAt,
-		arg.Title,
-		arg.Url,
-		arg.Description,
-		arg.PublishedAt,
 		arg.FeedID,
+		arg.Guid,
+		arg.ContentHash,
 	)
+	return err
+}
+
+const getPostByFeedAndGUID = `-- name: GetPostByFeedAndGUID :one
+SELECT id, created_at, updated_at, title, url, description, published_at, feed_id, content, guid, content_hash, published_at_inferred FROM posts WHERE feed_id = $1 AND guid = $2
+`
+
+type GetPostByFeedAndGUIDParams struct {
+	FeedID uuid.UUID
+	Guid   string
+}
+
+func (q *Queries) GetPostByFeedAndGUID(ctx context.Context, arg GetPostByFeedAndGUIDParams) (Post, error) {
+	row := q.db.QueryRowContext(ctx, getPostByFeedAndGUID, arg.FeedID, arg.Guid)
 	var i Post
 	err := row.Scan(
 		&i.ID,
@@ -53,41 +89,117 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		&i.Description,
 		&i.PublishedAt,
 		&i.FeedID,
+		&i.Content,
+		&i.Guid,
+		&i.ContentHash,
+		&i.PublishedAtInferred,
 	)
 	return i, err
 }
 
+const getPostByID = `-- name: GetPostByID :one
+SELECT id, created_at, updated_at, title, url, description, published_at, feed_id, content, guid, content_hash, published_at_inferred FROM posts WHERE id = $1
+`
+
+func (q *Queries) GetPostByID(ctx context.Context, id uuid.UUID) (Post, error) {
+	row := q.db.QueryRowContext(ctx, getPostByID, id)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.Content,
+		&i.Guid,
+		&i.ContentHash,
+		&i.PublishedAtInferred,
+	)
+	return i, err
+}
+
+const getPostRevisions = `-- name: GetPostRevisions :many
+SELECT id, created_at, post_id, title, url, description, content, content_hash FROM post_revisions
+WHERE post_id = $1
+ORDER BY created_at DESC
+`
+
+func (q *Queries) GetPostRevisions(ctx context.Context, postID uuid.UUID) ([]PostRevision, error) {
+	rows, err := q.db.QueryContext(ctx, getPostRevisions, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PostRevision
+	for rows.Next() {
+		var i PostRevision
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.PostID,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.Content,
+			&i.ContentHash,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getPostsForUser = `-- name: GetPostsForUser :many
-SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, feeds.name AS feed_name FROM posts
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.content, posts.guid, posts.content_hash, posts.published_at_inferred, feeds.name AS feed_name FROM posts
 JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
 JOIN feeds ON posts.feed_id = feeds.id
 WHERE feed_follows.user_id = $1
+AND (NOT $3::boolean OR NOT EXISTS (
+    SELECT 1 FROM post_reads
+    WHERE post_reads.user_id = feed_follows.user_id AND post_reads.post_id = posts.id
+))
 ORDER BY posts.published_at DESC
 LIMIT $2
 `
 
 type GetPostsForUserParams struct {
-	UserID uuid.UUID
-	Limit  int32
+	UserID     uuid.UUID
+	Limit      int32
+	UnreadOnly bool
 }
 
 type GetPostsForUserRow struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Title       string
-	Url         string
-	Description sql.NullString
-	PublishedAt sql.NullTime
-	FeedID      uuid.UUID
-	FeedName    string
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Title               string
+	Url                 string
+	Description         sql.NullString
+	PublishedAt         sql.NullTime
+	FeedID              uuid.UUID
+	Content             sql.NullString
+	Guid                string
+	ContentHash         sql.NullString
+	PublishedAtInferred bool
+	FeedName            string
 }
 
 // Add a "get posts for user" SQL query to the database:
+// With unread_only, posts the user has already read (see post_reads) are left out:
 // Order the results so that the most recent posts are first:
 // Make the number of posts returned configurable:
 func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]GetPostsForUserRow, error) {
-	rows, err := q.db.QueryContext(ctx, getPostsForUser, arg.UserID, arg.Limit)
+	rows, err := q.db.QueryContext(ctx, getPostsForUser, arg.UserID, arg.Limit, arg.UnreadOnly)
 	if err != nil {
 		return nil, err
 	}
@@ -104,6 +216,10 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 			&i.Description,
 			&i.PublishedAt,
 			&i.FeedID,
+			&i.Content,
+			&i.Guid,
+			&i.ContentHash,
+			&i.PublishedAtInferred,
 			&i.FeedName,
 		); err != nil {
 			return nil, err
@@ -118,3 +234,166 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 	}
 	return items, nil
 }
+
+const getRecentPublishTimesForFeed = `-- name: GetRecentPublishTimesForFeed :many
+SELECT published_at FROM posts
+WHERE feed_id = $1
+AND published_at IS NOT NULL
+AND NOT published_at_inferred
+AND published_at <= NOW()
+ORDER BY published_at DESC
+LIMIT $2
+`
+
+type GetRecentPublishTimesForFeedParams struct {
+	FeedID uuid.UUID
+	Limit  int32
+}
+
+// The publication times of a feed's most recent posts, newest first, which the adaptive
+// scheduler uses to estimate how often the feed publishes. Posts whose feed didn't date them
+// only have the time we first saw them, so they're left out:
+func (q *Queries) GetRecentPublishTimesForFeed(ctx context.Context, arg GetRecentPublishTimesForFeedParams) ([]sql.NullTime, error) {
+	rows, err := q.db.QueryContext(ctx, getRecentPublishTimesForFeed, arg.FeedID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []sql.NullTime
+	for rows.Next() {
+		var published_at sql.NullTime
+		if err := rows.Scan(&published_at); err != nil {
+			return nil, err
+		}
+		items = append(items, published_at)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const isPostPruned = `-- name: IsPostPruned :one
+SELECT EXISTS (
+    SELECT 1 FROM pruned_posts
+    WHERE pruned_posts.feed_id = $1
+    AND (pruned_posts.guid = $2 OR pruned_posts.guid = $3)
+)
+`
+
+type IsPostPrunedParams struct {
+	FeedID     uuid.UUID
+	Guid       string
+	LegacyGuid string
+}
+
+// Whether prune has deleted this post before. A post stored before we kept GUIDs was pruned
+// under its legacy GUID (see AdoptLegacyPost), so check that too:
+func (q *Queries) IsPostPruned(ctx context.Context, arg IsPostPrunedParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isPostPruned, arg.FeedID, arg.Guid, arg.LegacyGuid)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const prunePosts = `-- name: PrunePosts :one
+WITH pruned AS (
+    DELETE FROM posts
+    WHERE COALESCE(posts.published_at, posts.created_at) < NOW() - make_interval(secs => $1::integer)
+    AND NOT EXISTS (
+        SELECT 1 FROM post_stars WHERE post_stars.post_id = posts.id
+    )
+    RETURNING posts.feed_id, posts.guid
+), tombstones AS (
+    INSERT INTO pruned_posts (feed_id, guid, pruned_at)
+    SELECT pruned.feed_id, pruned.guid, $2 FROM pruned
+    ON CONFLICT DO NOTHING
+)
+SELECT count(*) FROM pruned
+`
+
+type PrunePostsParams struct {
+	MaxAgeSeconds int32
+	PrunedAt      time.Time
+}
+
+// Delete posts published more than max_age_seconds ago, along with their reads, enclosures
+// and revisions, and return how many were deleted. Starred posts are kept however old they
+// are. Each deleted post leaves its feed and GUID behind in pruned_posts, so agg won't store
+// it again (see IsPostPruned):
+func (q *Queries) PrunePosts(ctx context.Context, arg PrunePostsParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, prunePosts, arg.MaxAgeSeconds, arg.PrunedAt)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const upsertPost = `-- name: UpsertPost :one
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, content, guid, content_hash, published_at_inferred)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+ON CONFLICT (feed_id, guid) DO UPDATE
+SET title = EXCLUDED.title,
+    url = EXCLUDED.url,
+    description = EXCLUDED.description,
+    content = EXCLUDED.content,
+    content_hash = EXCLUDED.content_hash,
+    updated_at = EXCLUDED.updated_at
+WHERE posts.content_hash IS DISTINCT FROM EXCLUDED.content_hash
+RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id, content, guid, content_hash, published_at_inferred
+`
+
+type UpsertPostParams struct {
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Title               string
+	Url                 string
+	Description         sql.NullString
+	PublishedAt         sql.NullTime
+	FeedID              uuid.UUID
+	Content             sql.NullString
+	Guid                string
+	ContentHash         sql.NullString
+	PublishedAtInferred bool
+}
+
+// Add a "create post" SQL query to the database. This should insert
+// a new post into the database:
+// Posts are identified by their GUID within a feed. If we've already stored this one, update
+// it only when its content hash has changed (published_at and created_at are kept). When
+// nothing changed, RETURNING yields no row, which the caller sees as sql.ErrNoRows:
+func (q *Queries) UpsertPost(ctx context.Context, arg UpsertPostParams) (Post, error) {
+	row := q.db.QueryRowContext(ctx, upsertPost,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Title,
+		arg.Url,
+		arg.Description,
+		arg.PublishedAt,
+		arg.FeedID,
+		arg.Content,
+		arg.Guid,
+		arg.ContentHash,
+		arg.PublishedAtInferred,
+	)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.Content,
+		&i.Guid,
+		&i.ContentHash,
+		&i.PublishedAtInferred,
+	)
+	return i, err
+}
@@ -21,6 +21,8 @@ type state struct {
 	// Open a connection to the database, and store it in the state struct:
 	db  *database.Queries
 	cfg *config.Config
+	// sqlDB is the connection db runs its queries on, for commands that need a transaction:
+	sqlDB *sql.DB
 }
 
 func main() {
@@ -43,8 +45,9 @@ func main() {
 	// n the main function, remove the manual update of the config file. Instead, simply 
 	// read the config file, and store the config in a new instance of the state struct:
 	programState := &state{
-		db:  dbQueries,
-		cfg: &cfg,
+		db:    dbQueries,
+		cfg:   &cfg,
+		sqlDB: db,
 	}
 	// Create a new instance of the commands struct with an initialized map of handler functions:
 	cmds := commands{
@@ -65,6 +68,11 @@ func main() {
 	// Add a new feeds handler. It takes no arguments and prints all the feeds in the database 
 	// to the console:
 	cmds.register("feeds", handlerListFeeds)
+	// Give a feed its own fetch interval instead of agg's default:
+	cmds.register("setinterval", handlerSetFeedInterval)
+	// List feeds that are failing to fetch, and re-enable ones agg has given up on:
+	cmds.register("feedstatus", handlerFeedStatus)
+	cmds.register("enablefeed", handlerEnableFeed)
 	/* Add a follow command. It takes a single url argument and creates a new feed follow record 
 	for the current user. It should print the name of the feed and the current user once the record 
 	is created (which the query we just made should support). You'll need a query to look up feeds 
@@ -77,6 +85,23 @@ func main() {
 	cmds.register("unfollow", middlewareLoggedIn(handlerUnfollow))
 	// Add the browse command. It should take an optional "limit" parameter
 	cmds.register("browse", middlewareLoggedIn(handlerBrowse))
+	// Mark a post read without browsing to it:
+	cmds.register("read", middlewareLoggedIn(handlerRead))
+	// Star posts to keep them (prune never deletes them), and list them:
+	cmds.register("star", middlewareLoggedIn(handlerStar))
+	cmds.register("unstar", middlewareLoggedIn(handlerUnstar))
+	cmds.register("starred", middlewareLoggedIn(handlerStarred))
+	// Delete old posts to keep the database from growing forever (starred posts are kept):
+	cmds.register("prune", handlerPrune)
+	// Show the earlier versions of a post that was edited after we collected it:
+	cmds.register("revisions", handlerRevisions)
+	// Bring in the subscriptions exported (as OPML) from another feed reader:
+	cmds.register("import", middlewareLoggedIn(handlerImport))
+	// ...and write the current user's follows back out as OPML for other readers:
+	cmds.register("export", middlewareLoggedIn(handlerExport))
+	// Save everything to a portable JSON Lines file, and load it back (into any gator database):
+	cmds.register("backup", handlerBackup)
+	cmds.register("restore", handlerRestore)
 	/* If there are fewer than 2 arguments, print an error message to the terminal and exit. 
 	Why two? The first argument is automatically the program name, which we ignore, and we 
 	require a command name */
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/xml"
+	"strings"
+	"time"
+)
+
+// OPML is the format feed readers use to swap subscription lists. The subscriptions are
+// <outline> elements in the <body>; outlines without an xmlUrl are folders, and can be nested:
+// <opml version="2.0">
+//   <head><title>Subscriptions</title></head>
+//   <body>
+//     <outline text="Tech">
+//       <outline type="rss" text="The Go Blog" xmlUrl="https://go.dev/blog/feed.atom"/>
+//     </outline>
+//   </body>
+// </opml>
+type OPML struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    OPMLHead `xml:"head"`
+	Body    OPMLBody `xml:"body"`
+}
+
+type OPMLHead struct {
+	Title       string `xml:"title,omitempty"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+	OwnerName   string `xml:"ownerName,omitempty"`
+}
+
+type OPMLBody struct {
+	Outlines []OPMLOutline `xml:"outline"`
+}
+
+// The attributes are the ones OPML 1.0 and 2.0 define for subscription lists. text is the
+// only one that's required; title is an optional (and often identical) display name:
+type OPMLOutline struct {
+	Text        string `xml:"text,attr"`
+	Title       string `xml:"title,attr,omitempty"`
+	Type        string `xml:"type,attr,omitempty"`
+	XMLURL      string `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL     string `xml:"htmlUrl,attr,omitempty"`
+	Description string `xml:"description,attr,omitempty"`
+	// OPML 2.0 readers that use tags instead of folders put them here, comma-separated
+	// paths like "/Tech/Go,/Reading":
+	Category string        `xml:"category,attr,omitempty"`
+	Outlines []OPMLOutline `xml:"outline"`
+}
+
+// name is what a reader would display for the outline:
+func (outline OPMLOutline) name() string {
+	if title := strings.TrimSpace(outline.Title); title != "" {
+		return title
+	}
+	return strings.TrimSpace(outline.Text)
+}
+
+// opmlSubscription is a feed from an OPML file, along with the folder it was filed in:
+type opmlSubscription struct {
+	Outline OPMLOutline
+	// Folder is the path of folders the outline is nested in, joined with "/" ("" for none):
+	Folder string
+}
+
+// subscriptions flattens the outline tree into the list of feeds it contains, in order:
+func (opml *OPML) subscriptions() []opmlSubscription {
+	var subscriptions []opmlSubscription
+	var walk func(outlines []OPMLOutline, folders []string)
+	walk = func(outlines []OPMLOutline, folders []string) {
+		for _, outline := range outlines {
+			if strings.TrimSpace(outline.XMLURL) != "" {
+				folder := strings.Join(folders, "/")
+				// Without folders, fall back to the first OPML 2.0 category:
+				if folder == "" {
+					folder = strings.Trim(strings.TrimSpace(strings.Split(outline.Category, ",")[0]), "/")
+				}
+				subscriptions = append(subscriptions, opmlSubscription{
+					Outline: outline,
+					Folder:  folder,
+				})
+				continue
+			}
+			// Anything else is a folder; its name becomes part of its children's path:
+			walk(outline.Outlines, append(folders[:len(folders):len(folders)], strings.ReplaceAll(outline.name(), "/", "-")))
+		}
+	}
+	walk(opml.Body.Outlines, nil)
+	return subscriptions
+}
+
+// newOPML builds an OPML 2.0 document out of a list of subscriptions, the reverse of
+// subscriptions: each "/"-separated folder path becomes a set of nested folder outlines.
+// Within a folder, subfolders come before feeds, and both keep the order they're given in:
+func newOPML(title, ownerName string, subscriptions []opmlSubscription) *OPML {
+	root := &opmlFolder{}
+	for _, subscription := range subscriptions {
+		folder := root
+		for _, name := range strings.Split(subscription.Folder, "/") {
+			if name != "" {
+				folder = folder.subfolder(name)
+			}
+		}
+		folder.feeds = append(folder.feeds, subscription.Outline)
+	}
+
+	return &OPML{
+		Version: "2.0",
+		Head: OPMLHead{
+			Title:       title,
+			DateCreated: time.Now().UTC().Format(time.RFC1123Z),
+			OwnerName:   ownerName,
+		},
+		Body: OPMLBody{
+			Outlines: root.outlines(),
+		},
+	}
+}
+
+// opmlFolder is a folder in the tree newOPML builds before turning it into outlines:
+type opmlFolder struct {
+	name       string
+	subfolders []*opmlFolder
+	feeds      []OPMLOutline
+}
+
+// subfolder returns the folder's child with the given name, creating it if need be:
+func (folder *opmlFolder) subfolder(name string) *opmlFolder {
+	for _, subfolder := range folder.subfolders {
+		if subfolder.name == name {
+			return subfolder
+		}
+	}
+	subfolder := &opmlFolder{name: name}
+	folder.subfolders = append(folder.subfolders, subfolder)
+	return subfolder
+}
+
+// outlines returns the folder's contents as OPML outlines:
+func (folder *opmlFolder) outlines() []OPMLOutline {
+	var outlines []OPMLOutline
+	for _, subfolder := range folder.subfolders {
+		outlines = append(outlines, OPMLOutline{
+			Text:     subfolder.name,
+			Title:    subfolder.name,
+			Outlines: subfolder.outlines(),
+		})
+	}
+	return append(outlines, folder.feeds...)
+}
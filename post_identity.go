@@ -0,0 +1,78 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+)
+
+// These patterns strip the parts of a link that change without the post itself changing.
+// sql/schema/007_posts_guid.sql applies the exact same rewrites when backfilling existing
+// rows, so keep the two in sync:
+var (
+	linkSchemePattern   = regexp.MustCompile(`(?i)^https?://`)
+	linkFragmentPattern = regexp.MustCompile(`#.*$`)
+	// Tracking parameters only count as whole parameters, so "?notfbclid=1" is left alone.
+	// Removing one leaves its separator behind, which the next two patterns tidy up:
+	linkTrackingPattern  = regexp.MustCompile(`(?i)([?&])(utm_[a-z]+|fbclid|gclid)=[^&]*`)
+	linkSeparatorPattern = regexp.MustCompile(`([?&])&+`)
+	linkTrailingPattern  = regexp.MustCompile(`[?&]$`)
+)
+
+// postGUID returns the identity a feed item is stored under, unique within its feed. Most
+// feeds give every item a GUID (RSS <guid>, Atom <id>, JSON Feed id); for the ones that
+// don't, we fall back to a hash of the normalized link:
+func postGUID(item FeedItem) string {
+	if guid := strings.TrimSpace(item.GUID); guid != "" {
+		return guid
+	}
+	// Without a link there's nothing stable left to go on but the title:
+	if item.Link == "" {
+		return hashIdentity(item.Title)
+	}
+	return hashIdentity(normalizeLink(item.Link))
+}
+
+// legacyPostGUID returns the identity 007_posts_guid.sql gave the item if it was stored before
+// we kept GUIDs, when that differs from postGUID (and "" when it doesn't):
+func legacyPostGUID(item FeedItem) string {
+	if strings.TrimSpace(item.GUID) == "" || item.Link == "" {
+		return ""
+	}
+	return hashIdentity(normalizeLink(item.Link))
+}
+
+// normalizeLink strips a link's scheme, fragment and tracking parameters and lower-cases its
+// host, so "https://Example.com/post?utm_source=rss" and "http://example.com/post" are the
+// same. The rest keeps its case: plenty of sites have case-sensitive paths and query strings,
+// and "/p?id=aB" and "/p?id=Ab" can be different posts:
+func normalizeLink(link string) string {
+	normalized := strings.TrimSpace(link)
+	normalized = linkSchemePattern.ReplaceAllString(normalized, "")
+	if end := strings.IndexAny(normalized, "/?#"); end >= 0 {
+		normalized = strings.ToLower(normalized[:end]) + normalized[end:]
+	} else {
+		normalized = strings.ToLower(normalized)
+	}
+	normalized = linkFragmentPattern.ReplaceAllString(normalized, "")
+	normalized = linkTrackingPattern.ReplaceAllString(normalized, "${1}")
+	normalized = linkSeparatorPattern.ReplaceAllString(normalized, "${1}")
+	normalized = linkTrailingPattern.ReplaceAllString(normalized, "")
+	return normalized
+}
+
+func hashIdentity(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// contentHash fingerprints the parts of a post an author might edit. If the hash of an item
+// differs from the stored one, scrapeFeed updates the post and keeps the old version as a
+// revision. The migration that added content_hash computes the same thing in SQL:
+func contentHash(item FeedItem) string {
+	const separator = "\x1f"
+	value := item.Title + separator + item.Link + separator + item.Description + separator + item.Content
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
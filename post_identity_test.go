@@ -0,0 +1,88 @@
+package main
+
+import "testing"
+
+// Links that should (and shouldn't) come out as the same post. Keep these in line with the
+// backfill in sql/schema/007_posts_guid.sql, which has to compute the same thing:
+func TestNormalizeLink(t *testing.T) {
+	tests := []struct {
+		link string
+		want string
+	}{
+		{"https://example.com/post", "example.com/post"},
+		{"http://example.com/post", "example.com/post"},
+		{"HTTPS://Example.COM/post", "example.com/post"},
+		{"  https://example.com/post\n", "example.com/post"},
+		{"https://example.com", "example.com"},
+		{"https://Example.com:8080/post", "example.com:8080/post"},
+		// Only the host is case-insensitive:
+		{"https://example.com/Post/AbC", "example.com/Post/AbC"},
+		{"https://example.com/p?id=aB", "example.com/p?id=aB"},
+		{"https://example.com/p?id=Ab", "example.com/p?id=Ab"},
+		{"https://Example.com?Q=1", "example.com?Q=1"},
+		// Fragments:
+		{"https://example.com/post#comments", "example.com/post"},
+		{"https://Example.com#Top", "example.com"},
+		// Tracking parameters, wherever they are in the query:
+		{"https://example.com/post?utm_source=rss", "example.com/post"},
+		{"https://example.com/post?utm_source=rss&utm_medium=feed", "example.com/post"},
+		{"https://example.com/post?id=1&utm_source=rss", "example.com/post?id=1"},
+		{"https://example.com/post?utm_source=rss&id=1", "example.com/post?id=1"},
+		{"https://example.com/post?a=1&fbclid=x&b=2", "example.com/post?a=1&b=2"},
+		{"https://example.com/post?gclid=x#top", "example.com/post"},
+		{"https://example.com/post?UTM_Source=rss", "example.com/post"},
+		// ...but only whole parameters:
+		{"https://example.com/p?notfbclid=3", "example.com/p?notfbclid=3"},
+		{"https://example.com/p?my_utm_source=3", "example.com/p?my_utm_source=3"},
+		// Other schemes are kept, since they aren't interchangeable with http:
+		{"ftp://example.com/file", "ftp://example.com/file"},
+	}
+	for _, test := range tests {
+		if got := normalizeLink(test.link); got != test.want {
+			t.Errorf("normalizeLink(%q) = %q, want %q", test.link, got, test.want)
+		}
+	}
+}
+
+func TestPostGUID(t *testing.T) {
+	tests := []struct {
+		name       string
+		item       FeedItem
+		want       string
+		wantLegacy string
+	}{
+		{
+			name:       "the feed's own GUID",
+			item:       FeedItem{GUID: " tag:example.com,2025:1 ", Link: "https://example.com/1"},
+			want:       "tag:example.com,2025:1",
+			wantLegacy: hashIdentity("example.com/1"),
+		},
+		{
+			name: "the link when there's no GUID",
+			item: FeedItem{Link: "https://Example.com/1?utm_source=rss"},
+			want: hashIdentity("example.com/1"),
+		},
+		{
+			name: "the title when there's no link either",
+			item: FeedItem{Title: "Hello"},
+			want: hashIdentity("Hello"),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := postGUID(test.item); got != test.want {
+				t.Errorf("postGUID = %q, want %q", got, test.want)
+			}
+			if got := legacyPostGUID(test.item); got != test.wantLegacy {
+				t.Errorf("legacyPostGUID = %q, want %q", got, test.wantLegacy)
+			}
+		})
+	}
+
+	// Links that differ only in the case of their path are different posts:
+	first := postGUID(FeedItem{Link: "https://example.com/p?id=aB"})
+	second := postGUID(FeedItem{Link: "https://example.com/p?id=Ab"})
+	if first == second {
+		t.Error("links differing only in query case got the same GUID")
+	}
+}
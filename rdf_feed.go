@@ -0,0 +1,45 @@
+package main
+
+// RSS 1.0 is built on RDF: the root is <rdf:RDF>, and the <item> elements are siblings of
+// <channel> rather than children of it, so RSSFeed.Channel.Item never sees them:
+type RDFFeed struct {
+	Channel struct {
+		Title       string `xml:"title"`
+		Link        string `xml:"link"`
+		Description string `xml:"description"`
+	} `xml:"channel"`
+	Item []RDFItem `xml:"item"`
+}
+
+// RSS 1.0 has no pubDate of its own; publication time comes from the Dublin Core module
+// as <dc:date>, which is matched by its namespace URI:
+type RDFItem struct {
+	// Every RSS 1.0 item is identified by the rdf:about attribute on the <item> element:
+	About       string `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# about,attr"`
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+	Date        string `xml:"http://purl.org/dc/elements/1.1/ date"`
+	// RSS 1.0 feeds use the same content module as RSS 2.0 for full post bodies:
+	ContentEncoded string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+}
+
+// toFeedData converts an RSS 1.0 document into the common FeedData model:
+func (rdfFeed *RDFFeed) toFeedData() *FeedData {
+	feedData := &FeedData{
+		Title:       rdfFeed.Channel.Title,
+		Link:        rdfFeed.Channel.Link,
+		Description: rdfFeed.Channel.Description,
+	}
+	for _, item := range rdfFeed.Item {
+		feedData.Items = append(feedData.Items, FeedItem{
+			GUID:        item.About,
+			Title:       item.Title,
+			Link:        item.Link,
+			Description: item.Description,
+			Content:     item.ContentEncoded,
+			PubDates:    []string{item.Date},
+		})
+	}
+	return feedData
+}
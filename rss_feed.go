@@ -3,8 +3,8 @@ package main
 import (
 	"context"
 	"encoding/xml"
-	"html"
-	"io"
+	"errors"
+	"fmt"
 	"net/http"
 	"time"
 )
@@ -15,8 +15,14 @@ type RSSFeed struct {
 	Channel struct {
 		// Channel is an anonymous nested struct with fields Title, Link, Description, and Item:
 		Title       string    `xml:"title"`
-		Link        string    `xml:"link"`
+		// Links is every <link>, including any <atom:link rel="self">; see rssLink:
+		Links       []RSSLink `xml:"link"`
 		Description string    `xml:"description"`
+		// Scheduling hints from the publisher: don't fetch more often than every <ttl>
+		// minutes, or during the listed <skipHours> (GMT) and <skipDays>:
+		TTL       string   `xml:"ttl"`
+		SkipHours []string `xml:"skipHours>hour"`
+		SkipDays  []string `xml:"skipDays>day"`
 		// Item []RSSItem means multiple <item> elements become a slice of RSSItem:
 		Item        []RSSItem `xml:"item"`
 	// The tag on Channel, xml:"channel", tells the decoder to look for the <channel> element 
@@ -25,32 +31,134 @@ type RSSFeed struct {
 }
 
 type RSSItem struct {
-	Title       string `xml:"title"`
-	Link        string `xml:"link"`
-	Description string `xml:"description"`
-	PubDate     string `xml:"pubDate"`
+	GUID        string    `xml:"guid"`
+	Title       string    `xml:"title"`
+	Links       []RSSLink `xml:"link"`
+	Description string    `xml:"description"`
+	PubDate     string    `xml:"pubDate"`
+	// <content:encoded> holds the full post body when <description> is only a teaser:
+	ContentEncoded string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	// Some RSS 2.0 feeds carry their dates in the Dublin Core or Atom namespaces instead of
+	// (or as well as) <pubDate>:
+	DCDate      string `xml:"http://purl.org/dc/elements/1.1/ date"`
+	AtomUpdated string `xml:"http://www.w3.org/2005/Atom updated"`
+	// Podcasts attach the episode as an <enclosure>, and describe it with the itunes: namespace:
+	Enclosures     []RSSEnclosure `xml:"enclosure"`
+	ITunesDuration string         `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
 }
 
-// Write a func fetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) function. It 
+// A tag without a namespace, like xml:"link", matches <link> in any namespace, so it also picks
+// up the <atom:link rel="self" href="..."/> many feeds add next to their real <link> (and
+// which has no text). XMLName keeps the namespace, so rssLink can tell them apart:
+type RSSLink struct {
+	XMLName xml.Name
+	URL     string `xml:",chardata"`
+}
+
+// rssLink returns the URL in the RSS <link> element, skipping any <atom:link>:
+func rssLink(links []RSSLink) string {
+	for _, link := range links {
+		if link.XMLName.Space != "http://www.w3.org/2005/Atom" {
+			return link.URL
+		}
+	}
+	return ""
+}
+
+// <enclosure url="https://example.com/ep1.mp3" length="12345" type="audio/mpeg"/>
+type RSSEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length string `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// fetchRequest describes a single feed fetch. ETag and LastModified are the cache validators
+// from the previous successful response, if we have them; sending them back lets the server
+// answer 304 Not Modified instead of the whole feed:
+type fetchRequest struct {
+	URL          string
+	ETag         string
+	LastModified string
+	// MaxBytes is the largest response body (once decompressed) we'll read:
+	MaxBytes int64
+}
+
+// fetchResult is what came back from a fetch. When NotModified is true, Feed is nil and the
+// caller should keep using what it already stored:
+type fetchResult struct {
+	Feed         *FeedData
+	NotModified  bool
+	ETag         string
+	LastModified string
+	// StatusCode is the HTTP status the server answered with (200, 304, ...):
+	StatusCode int
+	// PermanentURL is where the feed has moved to, if fetching it went through permanent
+	// redirects (301 or 308). It's empty when the feed wasn't permanently redirected:
+	PermanentURL string
+}
+
+// httpStatusError is returned by fetchFeed when the server answers with an error status, so
+// callers can record which status it was (see RecordFeedFailure):
+type httpStatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (err *httpStatusError) Error() string {
+	return fmt.Sprintf("unexpected status: %s", err.Status)
+}
+
+// Write a func fetchFeed(ctx context.Context, feedURL string) (*FeedData, error) function. It 
 // should fetch a feed from the given URL, and, assuming that nothing goes wrong, return a 
-// filled-out RSSFeed struct:
-func fetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
+// filled-out FeedData struct (whatever format the feed itself is in):
+// (it now takes a fetchRequest, so fetches can be conditional)
+func fetchFeed(ctx context.Context, fetchReq fetchRequest) (*fetchResult, error) {
+	// The client follows redirects for us, but we want to know when the feed has moved for
+	// good. permanentURL is where the redirects from the feed's URL lead for as long as they
+	// are all permanent; a temporary redirect along the way (302, 307) ends the run, because
+	// it's only the URL before it that the publisher wants us to use from now on:
+	permanentURL := ""
+	onlyPermanent := true
 	// construct a new http.Client value using a composite literal:
 	httpClient := http.Client{
 		Timeout: 10 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			// Keep http.Client's default limit on redirects:
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			status := req.Response.StatusCode
+			if onlyPermanent && (status == http.StatusMovedPermanently || status == http.StatusPermanentRedirect) {
+				permanentURL = req.URL.String()
+			} else {
+				onlyPermanent = false
+			}
+			return nil
+		},
 	}
 	// construct an HTTP request object:
 		// Use ctx to control cancellation/timeouts
 		// Method is "GET"
-		// URL is feedURL
+		// URL is the feed's URL
 		// Body is nil (no request body)
-	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", fetchReq.URL, nil)
 	if err != nil {
 		return nil, err
 	}
 	// set the User-Agent header to gator in the request with request.Header.Set. 
 	// This is a common practice to identify your program to the server:
 	req.Header.Set("User-Agent", "gator")
+	// Tell the server which feed formats we understand, most specific first:
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/feed+json, application/xml;q=0.9, text/xml;q=0.9, */*;q=0.8")
+	// Ask for a compressed response; readFeedBody decodes it:
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	// Make the request conditional on the feed having changed since we last fetched it:
+	if fetchReq.ETag != "" {
+		req.Header.Set("If-None-Match", fetchReq.ETag)
+	}
+	if fetchReq.LastModified != "" {
+		req.Header.Set("If-Modified-Since", fetchReq.LastModified)
+	}
 	// send the HTTP request and return the server’s response:
 		// httpClient.Do(req) performs the network call
 		// resp is an *http.Response with status, headers, and Body (an io.ReadCloser)
@@ -62,43 +170,87 @@ func fetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
 	// You should defer resp.Body.Close() after checking err:
 	defer resp.Body.Close()
 
+	// 304 means nothing changed since the validators we sent, and there's no body to read:
+	if resp.StatusCode == http.StatusNotModified {
+		return &fetchResult{
+			NotModified:  true,
+			StatusCode:   resp.StatusCode,
+			PermanentURL: permanentURL,
+			ETag:         fetchReq.ETag,
+			LastModified: fetchReq.LastModified,
+		}, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &httpStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	// Don't bother downloading images, videos and the like:
+	contentType := resp.Header.Get("Content-Type")
+	if err := checkContentType(contentType); err != nil {
+		return nil, err
+	}
+
 	// read the entire HTTP response body into memory:
 		// io.ReadAll consumes resp.Body (an io.Reader) and returns a byte slice dat
 		// After this, you should close resp.Body (if you haven’t already deferred Close)
-	dat, err := io.ReadAll(resp.Body)
+	// (readFeedBody does this for us, after decompressing it and making sure it isn't huge)
+	dat, err := readFeedBody(resp, fetchReq.MaxBytes)
 	if err != nil {
 		return nil, err
 	}
 
-	// declare a new RSSFeed variable
-	// When you declare a variable this way without explicitly initializing it, Go automatically 
-	// sets it to the zero value for that type. For a struct like RSSFeed, the zero value means:
-		// All string fields will be empty strings ("")
-		// All int fields will be 0
-		// All slice fields will be nil
-		// All nested structs will also be zero-valued
-	var rssFeed RSSFeed
-	// after this declaration, you have an empty RSSFeed struct that you can then populate - 
-	// for example, by unmarshaling XML data into it:
-	err = xml.Unmarshal(dat, &rssFeed)	//  (works the same as json.Unmarshal)
+	// Hand the raw bytes to parseFeed, which works out whether this is an RSS 2.0, RSS 1.0,
+	// Atom or JSON Feed document and converts it into our common FeedData model. The
+	// Content-Type header helps it tell JSON apart from XML:
+	feedData, err := parseFeed(dat, contentType)
+	// A web page fails to parse with a confusing XML error, so say what actually went wrong:
+	if err != nil && isHTML(contentType) {
+		return nil, fmt.Errorf("%w: %s is a web page", errNotAFeed, fetchReq.URL)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	// Use the html.UnescapeString function to decode escaped HTML entities (like &ldquo;). 
-	// You'll need to run the Title and Description fields (of both the entire channel as well 
-	// as the items) through this function:
-		// &ldquo; becomes "
-		// &rdquo; becomes "
-		// &amp; becomes &
-		// &#39; becomes '
-	rssFeed.Channel.Title = html.UnescapeString(rssFeed.Channel.Title)
-	rssFeed.Channel.Description = html.UnescapeString(rssFeed.Channel.Description)
-	for i, item := range rssFeed.Channel.Item {
-		item.Title = html.UnescapeString(item.Title)
-		item.Description = html.UnescapeString(item.Description)
-		rssFeed.Channel.Item[i] = item
-	}
+	return &fetchResult{
+		Feed:         feedData,
+		StatusCode:   resp.StatusCode,
+		PermanentURL: permanentURL,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
 
-	return &rssFeed, nil
+// toFeedData converts an RSS 2.0 document into the common FeedData model:
+func (rssFeed *RSSFeed) toFeedData() *FeedData {
+	feedData := &FeedData{
+		Title:       rssFeed.Channel.Title,
+		Link:        rssLink(rssFeed.Channel.Links),
+		Description: rssFeed.Channel.Description,
+		Schedule:    parseScheduleHints(rssFeed.Channel.TTL, rssFeed.Channel.SkipHours, rssFeed.Channel.SkipDays),
+	}
+	for _, item := range rssFeed.Channel.Item {
+		// <itunes:duration> belongs to the item, which almost always has a single enclosure:
+		var enclosures []FeedEnclosure
+		for _, enclosure := range item.Enclosures {
+			if enclosure.URL == "" {
+				continue
+			}
+			enclosures = append(enclosures, FeedEnclosure{
+				URL:             enclosure.URL,
+				MimeType:        enclosure.Type,
+				Length:          parseLength(enclosure.Length),
+				DurationSeconds: parseITunesDuration(item.ITunesDuration),
+			})
+		}
+		feedData.Items = append(feedData.Items, FeedItem{
+			GUID:        item.GUID,
+			Title:       item.Title,
+			Link:        rssLink(item.Links),
+			Description: item.Description,
+			Content:     item.ContentEncoded,
+			PubDates:    []string{item.PubDate, item.DCDate, item.AtomUpdated},
+			Enclosures:  enclosures,
+		})
+	}
+	return feedData
 }
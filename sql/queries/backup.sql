@@ -0,0 +1,131 @@
+-- Queries for the backup and restore commands.
+--
+-- backup reads everything out in a stable order. Posts can run into the hundreds of
+-- thousands, so they're read a page at a time, continuing after the last ID of the previous
+-- page (pass the zero UUID to start):
+-- name: GetAllFeedFollows :many
+SELECT * FROM feed_follows ORDER BY created_at, id;
+--
+-- name: GetPostsPage :many
+SELECT * FROM posts
+WHERE id > sqlc.arg(after_id)
+ORDER BY id
+LIMIT sqlc.arg(page_size);
+--
+-- name: GetAllFeedPreviousURLs :many
+SELECT * FROM feed_previous_urls ORDER BY created_at, url;
+--
+-- name: GetAllPrunedPosts :many
+SELECT * FROM pruned_posts ORDER BY pruned_at, feed_id, guid;
+--
+-- Enclosures and revisions are paged like posts:
+-- name: GetEnclosuresPage :many
+SELECT * FROM enclosures
+WHERE id > sqlc.arg(after_id)
+ORDER BY id
+LIMIT sqlc.arg(page_size);
+--
+-- name: GetPostRevisionsPage :many
+SELECT * FROM post_revisions
+WHERE id > sqlc.arg(after_id)
+ORDER BY id
+LIMIT sqlc.arg(page_size);
+--
+-- Each restore query inserts the row from the backup unless the database already has it, and
+-- returns the ID the row has in this database: the backed-up ID when it was inserted, or the
+-- ID of the existing row with the same natural key (a user's name, a feed's URL, a post's
+-- feed and GUID), which may differ if the database has been used since. restored says
+-- whether the row was inserted. Because rows inserted by a statement aren't visible to the
+-- rest of that statement, the new row's ID comes from the inserted CTE instead of the table.
+-- When the insert only failed because the ID belongs to some other row, neither half returns
+-- anything, and restore reports the clash rather than pointing references at the wrong row.
+-- name: RestoreUser :one
+WITH inserted AS (
+    INSERT INTO users (id, created_at, updated_at, name)
+    VALUES (sqlc.arg(id), sqlc.arg(created_at), sqlc.arg(updated_at), sqlc.arg(name))
+    ON CONFLICT DO NOTHING
+    RETURNING id
+)
+SELECT id, true AS restored FROM inserted
+UNION ALL
+SELECT users.id, false AS restored FROM users
+WHERE users.name = sqlc.arg(name)
+ORDER BY restored DESC
+LIMIT 1;
+--
+-- name: RestoreFeed :one
+WITH inserted AS (
+    INSERT INTO feeds (id, created_at, updated_at, name, url, user_id, description, site_url, fetch_interval_seconds)
+    VALUES (
+        sqlc.arg(id), sqlc.arg(created_at), sqlc.arg(updated_at), sqlc.arg(name), sqlc.arg(url),
+        sqlc.arg(user_id), sqlc.narg(description), sqlc.narg(site_url), sqlc.narg(fetch_interval_seconds)
+    )
+    ON CONFLICT DO NOTHING
+    RETURNING id
+)
+SELECT id, true AS restored FROM inserted
+UNION ALL
+SELECT feeds.id, false AS restored FROM feeds
+WHERE feeds.url = sqlc.arg(url)
+    -- The feed may have moved since the backup was taken:
+    OR feeds.id IN (SELECT feed_id FROM feed_previous_urls WHERE feed_previous_urls.url = sqlc.arg(url))
+ORDER BY restored DESC
+LIMIT 1;
+--
+-- Follows aren't referred to by anything else, so there's no ID to map:
+-- name: RestoreFeedFollow :execrows
+INSERT INTO feed_follows (id, created_at, updated_at, user_id, feed_id, folder)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT DO NOTHING;
+--
+-- name: RestorePost :one
+WITH inserted AS (
+    INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, content, guid, content_hash, published_at_inferred)
+    VALUES (
+        sqlc.arg(id), sqlc.arg(created_at), sqlc.arg(updated_at), sqlc.arg(title), sqlc.arg(url),
+        sqlc.narg(description), sqlc.narg(published_at), sqlc.arg(feed_id), sqlc.narg(content),
+        sqlc.arg(guid), sqlc.narg(content_hash), sqlc.arg(published_at_inferred)
+    )
+    ON CONFLICT DO NOTHING
+    RETURNING id
+)
+SELECT id, true AS restored FROM inserted
+UNION ALL
+SELECT posts.id, false AS restored FROM posts
+WHERE posts.feed_id = sqlc.arg(feed_id) AND posts.guid = sqlc.arg(guid)
+ORDER BY restored DESC
+LIMIT 1;
+--
+-- Previous URLs, pruned posts, enclosures and revisions aren't referred to by anything either,
+-- but point at a feed or a post that restore may have mapped to another ID:
+-- name: RestoreFeedPreviousURL :execrows
+INSERT INTO feed_previous_urls (url, created_at, feed_id)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING;
+--
+-- name: RestorePrunedPost :execrows
+INSERT INTO pruned_posts (feed_id, guid, pruned_at)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING;
+--
+-- name: RestoreEnclosure :execrows
+INSERT INTO enclosures (id, created_at, updated_at, post_id, url, mime_type, length, duration_seconds)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT DO NOTHING;
+--
+-- name: RestorePostRevision :execrows
+INSERT INTO post_revisions (id, created_at, post_id, title, url, description, content, content_hash)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT DO NOTHING;
+--
+-- Reads point at a user and a post, both of which restore may have mapped to other IDs:
+-- name: RestorePostRead :execrows
+INSERT INTO post_reads (user_id, post_id, read_at)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING;
+--
+-- name: RestorePostStar :execrows
+INSERT INTO post_stars (user_id, post_id, starred_at)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING;
+--
\ No newline at end of file
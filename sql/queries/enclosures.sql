@@ -0,0 +1,21 @@
+-- Attach a media file to a post, or refresh its details if it's already attached:
+-- name: UpsertEnclosure :exec
+INSERT INTO enclosures (id, created_at, updated_at, post_id, url, mime_type, length, duration_seconds)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT (post_id, url) DO UPDATE
+SET mime_type = EXCLUDED.mime_type,
+    length = EXCLUDED.length,
+    duration_seconds = EXCLUDED.duration_seconds,
+    updated_at = EXCLUDED.updated_at;
+--
+-- Detach the media files a post no longer lists, keeping the ones in urls:
+-- name: DeleteStaleEnclosures :exec
+DELETE FROM enclosures
+WHERE post_id = $1
+AND NOT (url = ANY(sqlc.arg(urls)::TEXT[]));
+--
+-- name: GetEnclosuresForPost :many
+SELECT * FROM enclosures
+WHERE post_id = $1
+ORDER BY created_at ASC;
+--
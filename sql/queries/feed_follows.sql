@@ -17,8 +17,17 @@ INNER JOIN users ON inserted_feed_follow.user_id = users.id;
 --
 -- Add a GetFeedFollowsForUser query. It should return all the feed follows for a given user
 -- and include the names of the feeds and user in the result:
+-- (along with how many of each feed's posts the user hasn't read yet)
 -- name: GetFeedFollowsForUser :many
-SELECT feed_follows.*, feeds.name AS feed_name, users.name AS user_name
+SELECT feed_follows.*, feeds.name AS feed_name, users.name AS user_name,
+    (
+        SELECT COUNT(*) FROM posts
+        WHERE posts.feed_id = feed_follows.feed_id
+        AND NOT EXISTS (
+            SELECT 1 FROM post_reads
+            WHERE post_reads.user_id = feed_follows.user_id AND post_reads.post_id = posts.id
+        )
+    ) AS unread_count
 FROM feed_follows
 INNER JOIN feeds ON feed_follows.feed_id = feeds.id
 INNER JOIN users ON feed_follows.user_id = users.id
@@ -27,4 +36,23 @@ WHERE feed_follows.user_id = $1;
 -- Add a new SQL query to delete a feed follow record by user and feed id combination
 -- name: DeleteFeedFollow :exec
 DELETE FROM feed_follows WHERE feed_id = $1 AND user_id = $2;
+--
+-- Follow a feed while importing subscriptions from another reader. Following a feed twice is
+-- fine here: the existing follow is kept, and only picks up the imported folder if it isn't
+-- in one already. An existing follow comes back with its own ID rather than the one passed in:
+-- name: ImportFeedFollow :one
+INSERT INTO feed_follows (id, created_at, updated_at, user_id, feed_id, folder)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (user_id, feed_id) DO UPDATE
+SET folder = COALESCE(feed_follows.folder, EXCLUDED.folder),
+updated_at = EXCLUDED.updated_at
+RETURNING *;
+--
+-- Everything about the feeds a user follows, filed by folder, for exporting them as OPML:
+-- name: GetFollowedFeedsForUser :many
+SELECT sqlc.embed(feeds), feed_follows.folder
+FROM feed_follows
+INNER JOIN feeds ON feed_follows.feed_id = feeds.id
+WHERE feed_follows.user_id = $1
+ORDER BY feed_follows.folder NULLS FIRST, feeds.name;
 --
\ No newline at end of file
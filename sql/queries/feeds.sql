@@ -1,29 +1,176 @@
 -- name: CreateFeed :one
-INSERT INTO feeds (id, created_at, updated_at, name, url, user_id)
-VALUES ($1, $2, $3, $4, $5, $6)
+INSERT INTO feeds (id, created_at, updated_at, name, url, user_id, description, site_url)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 RETURNING *;
 
 -- name: GetFeeds :many
 SELECT * FROM feeds;
 
+-- A feed that has moved can still be found by any URL it used to have (see MoveFeedURL).
+-- If some other feed has since been added at an old URL, that one is the better match:
 -- name: GetFeedByURL :one
 SELECT * FROM feeds
-WHERE url = $1;
+WHERE feeds.url = $1
+OR feeds.id = (SELECT feed_previous_urls.feed_id FROM feed_previous_urls WHERE feed_previous_urls.url = $1)
+ORDER BY feeds.url = $1 DESC
+LIMIT 1;
 
 -- Add a MarkFeedFetched SQL query. It should simply set the last_fetched_at and updated_at 
 -- columns to the current time for a given feed (probably by ID is simplest):
--- name: MarkFeedFetched :one
+-- Add a GetNextFeedToFetch SQL query. It should return the next feed we should fetch posts from. 
+-- We want to scrape all the feeds in a continuous loop. A simple approach is to keep track of when 
+-- a feed was last fetched, and always fetch the oldest one first (or any that haven't ever been 
+-- fetched). SQL has a NULLS FIRST clause that can help with this
+-- Several agg processes can share one database, so selecting and marking happen in a single
+-- statement: FOR UPDATE SKIP LOCKED makes each process skip rows another one is claiming
+-- right now.
+-- Feeds are fetched when they're due (next_fetch_at is before due_before), most overdue first.
+-- Claiming a feed schedules its next fetch one fetch interval from now. Disabled feeds are
+-- never claimed:
+-- name: ClaimFeedsToFetch :many
+WITH due AS (
+    SELECT id, last_fetched_at, next_fetch_at FROM feeds
+    WHERE (next_fetch_at IS NULL OR next_fetch_at <= sqlc.arg(due_before)::timestamptz)
+    AND disabled_at IS NULL
+    ORDER BY next_fetch_at ASC NULLS FIRST
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+)
 UPDATE feeds
 SET last_fetched_at = NOW(),
+next_fetch_at = NOW() + make_interval(secs => COALESCE(feeds.fetch_interval_seconds, sqlc.arg(default_interval_seconds)::integer)),
 updated_at = NOW()
-WHERE id = $1
+FROM due
+WHERE feeds.id = due.id
+RETURNING sqlc.embed(feeds), due.last_fetched_at AS previous_fetched_at, due.next_fetch_at AS previous_next_fetch_at;
+
+-- Store the cache validators from a feed's latest 200 response, for the next conditional GET:
+-- name: UpdateFeedCacheValidators :exec
+UPDATE feeds
+SET etag = $2,
+last_modified = $3,
+updated_at = NOW()
+WHERE id = $1;
+
+-- Hand a claimed feed back when agg shuts down before fetching it, restoring the
+-- last_fetched_at and next_fetch_at it had before ClaimFeedsToFetch so it's still due:
+-- name: ReleaseFeedClaim :exec
+UPDATE feeds
+SET last_fetched_at = $2,
+next_fetch_at = $3,
+updated_at = NOW()
+WHERE id = $1;
+
+-- Set how often a feed is fetched, or pass NULL to go back to agg's default. A shorter
+-- interval takes effect straight away: the next fetch is brought forward if it's now overdue:
+-- name: SetFeedFetchInterval :one
+UPDATE feeds
+SET fetch_interval_seconds = sqlc.narg(fetch_interval_seconds),
+next_fetch_at = CASE
+    WHEN sqlc.narg(fetch_interval_seconds)::integer IS NULL OR next_fetch_at IS NULL THEN next_fetch_at
+    ELSE LEAST(next_fetch_at, COALESCE(last_fetched_at, NOW()) + make_interval(secs => sqlc.narg(fetch_interval_seconds)::integer))
+END,
+updated_at = NOW()
+WHERE id = sqlc.arg(id)
 RETURNING *;
 
--- Add a GetNextFeedToFetch SQL query. It should return the next feed we should fetch posts from. 
--- We want to scrape all the feeds in a continuous loop. A simple approach is to keep track of when 
--- a feed was last fetched, and always fetch the oldest one first (or any that haven't ever been 
--- fetched). SQL has a NULLS FIRST clause that can help with this
--- name: GetNextFeedToFetch :one
+-- Remember the <ttl>, <skipHours> and <skipDays> hints from a feed's latest 200 response:
+-- name: UpdateFeedScheduleHints :exec
+UPDATE feeds
+SET ttl_minutes = $2,
+skip_hours = $3,
+skip_days = $4,
+updated_at = NOW()
+WHERE id = $1;
+
+-- Schedule a feed's next fetch delay_seconds from now. The delay is worked out by the adaptive
+-- scheduler in Go, but the timestamp comes from the database clock like everywhere else:
+-- name: SetFeedNextFetch :exec
+UPDATE feeds
+SET next_fetch_at = NOW() + make_interval(secs => sqlc.arg(delay_seconds)),
+updated_at = NOW()
+WHERE id = sqlc.arg(id);
+
+-- Record a failed fetch. Each failure in a row doubles the wait before the next attempt,
+-- starting from the feed's usual interval and capped at max_backoff_seconds, and the feed is
+-- disabled once it has failed max_failures times in a row (0 means never disable it).
+-- In SET, consecutive_failures still refers to the count before this failure:
+-- name: RecordFeedFailure :one
+UPDATE feeds
+SET consecutive_failures = consecutive_failures + 1,
+last_error = sqlc.arg(last_error),
+last_error_at = NOW(),
+last_http_status = sqlc.narg(last_http_status),
+next_fetch_at = NOW() + make_interval(secs => LEAST(
+    COALESCE(fetch_interval_seconds, sqlc.arg(default_interval_seconds)::integer) * power(2, consecutive_failures),
+    sqlc.arg(max_backoff_seconds)::integer
+)),
+disabled_at = CASE
+    WHEN sqlc.arg(max_failures)::integer > 0 AND consecutive_failures + 1 >= sqlc.arg(max_failures)::integer THEN NOW()
+    ELSE disabled_at
+END,
+updated_at = NOW()
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- Record a successful fetch, which ends any run of failures. last_error is kept so
+-- feedstatus can still show what went wrong most recently:
+-- name: RecordFeedSuccess :exec
+UPDATE feeds
+SET consecutive_failures = 0,
+last_http_status = $2,
+updated_at = NOW()
+WHERE id = $1;
+
+-- Feeds that are failing or have been disabled, worst first:
+-- name: GetUnhealthyFeeds :many
 SELECT * FROM feeds
-ORDER BY last_fetched_at ASC NULLS FIRST
-LIMIT 1;
\ No newline at end of file
+WHERE consecutive_failures > 0 OR disabled_at IS NOT NULL
+ORDER BY disabled_at IS NULL, consecutive_failures DESC, name;
+
+-- Put a disabled feed back into rotation with a clean slate. It's due straight away:
+-- name: EnableFeed :one
+UPDATE feeds
+SET disabled_at = NULL,
+consecutive_failures = 0,
+next_fetch_at = NULL,
+updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- Note that fetching a feed's URL permanently redirected to redirect_url. redirect_count
+-- counts how many fetches in a row have been redirected to that same place:
+-- name: RecordFeedRedirect :one
+UPDATE feeds
+SET redirect_count = CASE WHEN redirect_url = sqlc.arg(redirect_url)::text THEN redirect_count + 1 ELSE 1 END,
+redirect_url = sqlc.arg(redirect_url)::text,
+updated_at = NOW()
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- A fetch that wasn't permanently redirected breaks any run of redirects:
+-- name: ClearFeedRedirect :exec
+UPDATE feeds
+SET redirect_url = NULL,
+redirect_count = 0,
+updated_at = NOW()
+WHERE id = $1 AND redirect_url IS NOT NULL;
+
+-- Move a feed to a new URL, remembering the old one in feed_previous_urls. If the feed is
+-- moving back to a URL it used to have, that URL isn't a previous one any more:
+-- name: MoveFeedURL :one
+WITH forgotten AS (
+    DELETE FROM feed_previous_urls
+    WHERE feed_previous_urls.url = sqlc.arg(url)
+), remembered AS (
+    INSERT INTO feed_previous_urls (url, created_at, feed_id)
+    SELECT feeds.url, NOW(), feeds.id FROM feeds WHERE id = sqlc.arg(id)
+    ON CONFLICT (url) DO UPDATE SET created_at = EXCLUDED.created_at, feed_id = EXCLUDED.feed_id
+)
+UPDATE feeds
+SET url = sqlc.arg(url),
+redirect_url = NULL,
+redirect_count = 0,
+updated_at = NOW()
+WHERE feeds.id = sqlc.arg(id)
+RETURNING *;
\ No newline at end of file
@@ -0,0 +1,10 @@
+-- Mark a post read for a user. Reading it again keeps the time it was first read, and
+-- affects no rows:
+-- name: MarkPostRead :execrows
+INSERT INTO post_reads (user_id, post_id, read_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, post_id) DO NOTHING;
+--
+-- name: GetAllPostReads :many
+SELECT * FROM post_reads ORDER BY read_at, user_id, post_id;
+--
\ No newline at end of file
@@ -0,0 +1,22 @@
+-- Starring a post that's already starred keeps the original time, and affects no rows:
+-- name: StarPost :execrows
+INSERT INTO post_stars (user_id, post_id, starred_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, post_id) DO NOTHING;
+--
+-- name: UnstarPost :execrows
+DELETE FROM post_stars WHERE user_id = $1 AND post_id = $2;
+--
+-- A user's starred posts, most recently starred first. Unlike GetPostsForUser, this doesn't
+-- depend on feed_follows, so a post stays starred after its feed is unfollowed:
+-- name: GetStarredPostsForUser :many
+SELECT posts.*, feeds.name AS feed_name, post_stars.starred_at FROM post_stars
+JOIN posts ON post_stars.post_id = posts.id
+JOIN feeds ON posts.feed_id = feeds.id
+WHERE post_stars.user_id = $1
+ORDER BY post_stars.starred_at DESC
+LIMIT $2;
+--
+-- name: GetAllPostStars :many
+SELECT * FROM post_stars ORDER BY starred_at, user_id, post_id;
+--
\ No newline at end of file
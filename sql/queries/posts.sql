@@ -1,18 +1,108 @@
 -- Add a "create post" SQL query to the database. This should insert 
 -- a new post into the database:
--- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+-- Posts are identified by their GUID within a feed. If we've already stored this one, update
+-- it only when its content hash has changed (published_at and created_at are kept). When
+-- nothing changed, RETURNING yields no row, which the caller sees as sql.ErrNoRows:
+-- name: UpsertPost :one
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, content, guid, content_hash, published_at_inferred)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+ON CONFLICT (feed_id, guid) DO UPDATE
+SET title = EXCLUDED.title,
+    url = EXCLUDED.url,
+    description = EXCLUDED.description,
+    content = EXCLUDED.content,
+    content_hash = EXCLUDED.content_hash,
+    updated_at = EXCLUDED.updated_at
+WHERE posts.content_hash IS DISTINCT FROM EXCLUDED.content_hash
 RETURNING *;
 --
+-- Posts stored before we kept GUIDs were given the hash of their link as one (see
+-- 007_posts_guid.sql). The first time we see such a post under its real GUID, move the old row
+-- over to it, so UpsertPost finds it instead of storing the post a second time. Does nothing
+-- once the row has been adopted, or if the post is already stored under its real GUID:
+-- name: AdoptLegacyPost :exec
+UPDATE posts SET guid = sqlc.arg(guid)
+WHERE posts.feed_id = sqlc.arg(feed_id)
+AND posts.guid = sqlc.arg(legacy_guid)
+AND NOT EXISTS (
+    SELECT 1 FROM posts AS adopted
+    WHERE adopted.feed_id = sqlc.arg(feed_id) AND adopted.guid = sqlc.arg(guid)
+);
+--
+-- Copy the stored version of a post into post_revisions before UpsertPost overwrites it.
+-- Inserts nothing if the post is new or its content hash hasn't changed:
+-- name: CreatePostRevision :exec
+INSERT INTO post_revisions (id, created_at, post_id, title, url, description, content, content_hash)
+SELECT sqlc.arg(id), sqlc.arg(created_at), posts.id, posts.title, posts.url, posts.description, posts.content, posts.content_hash
+FROM posts
+WHERE posts.feed_id = sqlc.arg(feed_id)
+AND posts.guid = sqlc.arg(guid)
+AND posts.content_hash IS DISTINCT FROM sqlc.arg(content_hash);
+--
+-- name: GetPostRevisions :many
+SELECT * FROM post_revisions
+WHERE post_id = $1
+ORDER BY created_at DESC;
+--
+-- name: GetPostByID :one
+SELECT * FROM posts WHERE id = $1;
+--
+-- name: GetPostByFeedAndGUID :one
+SELECT * FROM posts WHERE feed_id = $1 AND guid = $2;
+--
 -- Add a "get posts for user" SQL query to the database:
+-- With unread_only, posts the user has already read (see post_reads) are left out:
 -- name: GetPostsForUser :many
 SELECT posts.*, feeds.name AS feed_name FROM posts
 JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
 JOIN feeds ON posts.feed_id = feeds.id
 WHERE feed_follows.user_id = $1
+AND (NOT sqlc.arg(unread_only)::boolean OR NOT EXISTS (
+    SELECT 1 FROM post_reads
+    WHERE post_reads.user_id = feed_follows.user_id AND post_reads.post_id = posts.id
+))
 -- Order the results so that the most recent posts are first:
 ORDER BY posts.published_at DESC
 -- Make the number of posts returned configurable:
 LIMIT $2;
+--
+-- The publication times of a feed's most recent posts, newest first, which the adaptive
+-- scheduler uses to estimate how often the feed publishes. Posts whose feed didn't date them
+-- only have the time we first saw them, so they're left out:
+-- name: GetRecentPublishTimesForFeed :many
+SELECT published_at FROM posts
+WHERE feed_id = $1
+AND published_at IS NOT NULL
+AND NOT published_at_inferred
+AND published_at <= NOW()
+ORDER BY published_at DESC
+LIMIT $2;
+--
+-- Delete posts published more than max_age_seconds ago, along with their reads, enclosures
+-- and revisions, and return how many were deleted. Starred posts are kept however old they
+-- are. Each deleted post leaves its feed and GUID behind in pruned_posts, so agg won't store
+-- it again (see IsPostPruned):
+-- name: PrunePosts :one
+WITH pruned AS (
+    DELETE FROM posts
+    WHERE COALESCE(posts.published_at, posts.created_at) < NOW() - make_interval(secs => sqlc.arg(max_age_seconds)::integer)
+    AND NOT EXISTS (
+        SELECT 1 FROM post_stars WHERE post_stars.post_id = posts.id
+    )
+    RETURNING posts.feed_id, posts.guid
+), tombstones AS (
+    INSERT INTO pruned_posts (feed_id, guid, pruned_at)
+    SELECT pruned.feed_id, pruned.guid, sqlc.arg(pruned_at) FROM pruned
+    ON CONFLICT DO NOTHING
+)
+SELECT count(*) FROM pruned;
+--
+-- Whether prune has deleted this post before. A post stored before we kept GUIDs was pruned
+-- under its legacy GUID (see AdoptLegacyPost), so check that too:
+-- name: IsPostPruned :one
+SELECT EXISTS (
+    SELECT 1 FROM pruned_posts
+    WHERE pruned_posts.feed_id = sqlc.arg(feed_id)
+    AND (pruned_posts.guid = sqlc.arg(guid) OR pruned_posts.guid = sqlc.arg(legacy_guid))
+);
 --
\ No newline at end of file
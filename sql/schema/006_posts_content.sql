@@ -0,0 +1,8 @@
+-- Add a content column to the posts table. description is often just a one-line teaser, so
+-- this holds the full article body from <content:encoded>, Atom <content> or JSON Feed
+-- content_html/content_text. It's nullable because many feeds don't provide one:
+-- +goose Up
+ALTER TABLE posts ADD COLUMN content TEXT;
+
+-- +goose Down
+ALTER TABLE posts DROP COLUMN content;
\ No newline at end of file
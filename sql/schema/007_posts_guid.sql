@@ -0,0 +1,44 @@
+-- Key posts on (feed_id, guid) instead of a globally unique URL. Two feeds can link the same
+-- article, and a feed that switches from http to https (or adds tracking params) would
+-- otherwise look like it published everything again:
+-- +goose Up
+ALTER TABLE posts ADD COLUMN guid TEXT;
+
+-- Backfill existing rows with the same fallback identity scrapeFeed computes for items that
+-- have no GUID of their own (see postGUID in post_identity.go): a SHA-256 of the URL with the
+-- surrounding whitespace, scheme, fragment and tracking parameters stripped, and the host (but
+-- only the host) lower-cased. Items whose feed does carry a GUID are moved over to it the next
+-- time the feed is fetched (see AdoptLegacyPost):
+UPDATE posts SET guid = 'sha256:' || encode(sha256(convert_to(
+    regexp_replace(
+        regexp_replace(
+            regexp_replace(
+                regexp_replace(
+                    lower(substring(links.stripped from '^[^/?#]*')) || coalesce(substring(links.stripped from '[/?#].*$'), ''),
+                '#.*$', ''),
+            '([?&])(utm_[a-z]+|fbclid|gclid)=[^&]*', '\1', 'gi'),
+        '([?&])&+', '\1', 'g'),
+    '[?&]$', ''),
+'UTF8')), 'hex')
+FROM (
+    SELECT id, regexp_replace(btrim(url, E' \t\n\r\f\x0B'), '^https?://', '', 'i') AS stripped FROM posts
+) AS links
+WHERE posts.id = links.id;
+
+-- Only the URL was unique until now, so a feed that moved from http to https or started adding
+-- tracking parameters already has the same post stored twice, and both copies just got the
+-- same guid. Keep the oldest copy of each and delete the rest:
+DELETE FROM posts AS duplicate
+USING posts AS original
+WHERE duplicate.feed_id = original.feed_id
+AND duplicate.guid = original.guid
+AND (original.created_at, original.id) < (duplicate.created_at, duplicate.id);
+
+ALTER TABLE posts ALTER COLUMN guid SET NOT NULL;
+ALTER TABLE posts DROP CONSTRAINT posts_url_key;
+ALTER TABLE posts ADD CONSTRAINT posts_feed_id_guid_key UNIQUE (feed_id, guid);
+
+-- +goose Down
+ALTER TABLE posts DROP CONSTRAINT posts_feed_id_guid_key;
+ALTER TABLE posts ADD CONSTRAINT posts_url_key UNIQUE (url);
+ALTER TABLE posts DROP COLUMN guid;
\ No newline at end of file
@@ -0,0 +1,26 @@
+-- Track a hash of each post's content so scrapeFeed can tell when an author edits a post,
+-- and keep the previous versions in a post_revisions table:
+-- +goose Up
+ALTER TABLE posts ADD COLUMN content_hash TEXT;
+
+-- Backfill with the same hash contentHash in post_identity.go computes, so existing posts
+-- aren't all seen as "changed" on the next fetch:
+UPDATE posts SET content_hash = encode(sha256(convert_to(
+    title || chr(31) || url || chr(31) || coalesce(description, '') || chr(31) || coalesce(content, ''),
+'UTF8')), 'hex');
+
+CREATE TABLE post_revisions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,  -- when the change was detected
+    post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,   -- the post that changed
+    -- the post as it was before the change:
+    title TEXT NOT NULL,
+    url TEXT NOT NULL,
+    description TEXT,
+    content TEXT,
+    content_hash TEXT
+);
+
+-- +goose Down
+DROP TABLE post_revisions;
+ALTER TABLE posts DROP COLUMN content_hash;
\ No newline at end of file
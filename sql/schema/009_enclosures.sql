@@ -0,0 +1,18 @@
+-- Add an enclosures table for the media files attached to posts (podcast episodes, mostly).
+-- A post can have more than one, each linked back to the post it came with:
+-- +goose Up
+CREATE TABLE enclosures (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,  -- where the media file lives
+    mime_type TEXT,     -- e.g. audio/mpeg
+    length BIGINT,      -- size in bytes, as reported by the feed
+    duration_seconds INTEGER,   -- running time from <itunes:duration>
+    -- the same file should only be attached to a post once:
+    UNIQUE (post_id, url)
+);
+
+-- +goose Down
+DROP TABLE enclosures;
\ No newline at end of file
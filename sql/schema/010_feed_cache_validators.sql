@@ -0,0 +1,10 @@
+-- Remember the ETag and Last-Modified headers from the last successful fetch of each feed, so
+-- the next request can be conditional and the server can answer 304 Not Modified instead of
+-- sending the whole feed again. Both are nullable since not every server sends them:
+-- +goose Up
+ALTER TABLE feeds ADD COLUMN etag TEXT;
+ALTER TABLE feeds ADD COLUMN last_modified TEXT;
+
+-- +goose Down
+ALTER TABLE feeds DROP COLUMN last_modified;
+ALTER TABLE feeds DROP COLUMN etag;
\ No newline at end of file
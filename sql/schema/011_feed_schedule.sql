@@ -0,0 +1,13 @@
+-- Give every feed its own schedule. fetch_interval_seconds is how often the feed should be
+-- fetched (NULL means "use agg's default"), and next_fetch_at is when it's next due (NULL
+-- means it's due right away, which is what existing and newly added feeds start with):
+-- +goose Up
+ALTER TABLE feeds ADD COLUMN fetch_interval_seconds INTEGER;
+ALTER TABLE feeds ADD COLUMN next_fetch_at TIMESTAMP;
+-- agg looks feeds up by due time on every tick:
+CREATE INDEX feeds_next_fetch_at_idx ON feeds (next_fetch_at ASC NULLS FIRST);
+
+-- +goose Down
+DROP INDEX feeds_next_fetch_at_idx;
+ALTER TABLE feeds DROP COLUMN next_fetch_at;
+ALTER TABLE feeds DROP COLUMN fetch_interval_seconds;
\ No newline at end of file
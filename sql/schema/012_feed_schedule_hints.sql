@@ -0,0 +1,11 @@
+-- Store the scheduling hints an RSS feed gives about itself, so agg can honor them even when a
+-- fetch comes back 304 Not Modified with no body to read them from:
+-- +goose Up
+ALTER TABLE feeds ADD COLUMN ttl_minutes INTEGER;  -- <ttl>: don't fetch more often than this
+ALTER TABLE feeds ADD COLUMN skip_hours INTEGER[]; -- <skipHours>: hours (0-23, GMT) not to fetch in
+ALTER TABLE feeds ADD COLUMN skip_days TEXT[];     -- <skipDays>: days ("Monday", ...) not to fetch on
+
+-- +goose Down
+ALTER TABLE feeds DROP COLUMN skip_days;
+ALTER TABLE feeds DROP COLUMN skip_hours;
+ALTER TABLE feeds DROP COLUMN ttl_minutes;
\ No newline at end of file
@@ -0,0 +1,18 @@
+-- Follow feeds that have moved. redirect_url is where the feed's URL last permanently
+-- redirected to (301/308) and redirect_count is how many fetches in a row have seen that same
+-- redirect; once it's consistent, the feed's url is updated and the old one is kept in
+-- feed_previous_urls so it can still be used to look the feed up:
+-- +goose Up
+ALTER TABLE feeds ADD COLUMN redirect_url TEXT;
+ALTER TABLE feeds ADD COLUMN redirect_count INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE feed_previous_urls (
+    url TEXT PRIMARY KEY,   -- a URL the feed used to live at
+    created_at TIMESTAMP NOT NULL,  -- when the feed moved away from it
+    feed_id UUID NOT NULL REFERENCES feeds(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE feed_previous_urls;
+ALTER TABLE feeds DROP COLUMN redirect_count;
+ALTER TABLE feeds DROP COLUMN redirect_url;
\ No newline at end of file
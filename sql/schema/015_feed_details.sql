@@ -0,0 +1,10 @@
+-- Keep what a feed says about itself when it's added: its description, and the link to the
+-- website it belongs to (<channel><link> in RSS, the alternate link in Atom, home_page_url
+-- in JSON Feed):
+-- +goose Up
+ALTER TABLE feeds ADD COLUMN description TEXT;
+ALTER TABLE feeds ADD COLUMN site_url TEXT;
+
+-- +goose Down
+ALTER TABLE feeds DROP COLUMN site_url;
+ALTER TABLE feeds DROP COLUMN description;
\ No newline at end of file
@@ -0,0 +1,8 @@
+-- Let each user file the feeds they follow into folders, like other feed readers do. Nested
+-- folders are stored as one path joined with "/" (e.g. "Tech/Go"), and NULL means the feed
+-- isn't in a folder:
+-- +goose Up
+ALTER TABLE feed_follows ADD COLUMN folder TEXT;
+
+-- +goose Down
+ALTER TABLE feed_follows DROP COLUMN folder;
\ No newline at end of file
@@ -0,0 +1,12 @@
+-- Remember which posts each user has read, so browse can tell new posts from ones they've
+-- already seen. A post is read for a user if it has a row here:
+-- +goose Up
+CREATE TABLE post_reads (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    read_at TIMESTAMP NOT NULL,     -- when the user first saw the post
+    PRIMARY KEY (user_id, post_id)
+);
+
+-- +goose Down
+DROP TABLE post_reads;
\ No newline at end of file
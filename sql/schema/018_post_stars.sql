@@ -0,0 +1,14 @@
+-- Let users star posts they want to keep. PrunePosts leaves starred posts alone; as a
+-- backstop, the reference to the post doesn't cascade like post_reads does, so any other
+-- delete that reaches a starred post fails instead of quietly losing the star. (NO ACTION
+-- rather than RESTRICT, so that deleting a user, which also removes their stars, still works.)
+-- +goose Up
+CREATE TABLE post_stars (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    post_id UUID NOT NULL REFERENCES posts(id) ON DELETE NO ACTION,
+    starred_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, post_id)
+);
+
+-- +goose Down
+DROP TABLE post_stars;
\ No newline at end of file
@@ -0,0 +1,14 @@
+-- Remember which posts got the time we first saw them as their published_at, because the feed
+-- didn't give them a date we could parse. Those aren't real publication times, so the adaptive
+-- scheduler leaves them out of its estimate of how often a feed publishes:
+-- +goose Up
+ALTER TABLE posts ADD COLUMN published_at_inferred BOOLEAN NOT NULL DEFAULT false;
+
+-- scrapeFeed set published_at and created_at to the same moment for those posts, so existing
+-- ones can be picked out by their timestamps:
+UPDATE posts SET published_at_inferred = true
+WHERE published_at IS NOT NULL
+AND abs(extract(epoch FROM published_at - created_at)) < 1;
+
+-- +goose Down
+ALTER TABLE posts DROP COLUMN published_at_inferred;
\ No newline at end of file
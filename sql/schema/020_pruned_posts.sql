@@ -0,0 +1,12 @@
+-- Remember the posts prune has deleted, by their feed and GUID, so agg doesn't store them all
+-- over again the next time it fetches a feed that still lists them:
+-- +goose Up
+CREATE TABLE pruned_posts (
+    feed_id UUID NOT NULL REFERENCES feeds(id) ON DELETE CASCADE,
+    guid TEXT NOT NULL,
+    pruned_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (feed_id, guid)
+);
+
+-- +goose Down
+DROP TABLE pruned_posts;
\ No newline at end of file